@@ -0,0 +1,31 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// parseEnv sets each flag that wasn't given on the command line from the
+// environment, if the corresponding variable is set. The variable name is the
+// flag name, uppercased, with dots replaced by underscores, and prefixed, so
+// e.g. -sum.rate is ADDSVC_SUM_RATE. Flags take precedence.
+func parseEnv(fs *flag.FlagSet, prefix string) error {
+	given := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || given[f.Name] {
+			return
+		}
+		key := prefix + strings.ToUpper(strings.Replace(f.Name, ".", "_", -1))
+		if v, ok := os.LookupEnv(key); ok {
+			if setErr := fs.Set(f.Name, v); setErr != nil {
+				err = fmt.Errorf("%s: %v", key, setErr)
+			}
+		}
+	})
+	return err
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"testing"
+
+	"github.com/peterbourgon/go-microservices/addsvc/pkg/endpoints"
+)
+
+func TestConfigFlagsAndEnv(t *testing.T) {
+	config := endpoints.DefaultConfig()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	config.Sum.Flags(fs, "sum")
+	config.Concat.Flags(fs, "concat")
+	if err := fs.Parse([]string{"-sum.rate", "5"}); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("TEST_SUM_RATE", "7")               // overridden by the flag
+	os.Setenv("TEST_CONCAT_BREAKER_RATIO", "0.5") // not given as a flag
+	defer os.Unsetenv("TEST_SUM_RATE")
+	defer os.Unsetenv("TEST_CONCAT_BREAKER_RATIO")
+	if err := parseEnv(fs, "TEST_"); err != nil {
+		t.Fatal(err)
+	}
+
+	if want, have := 5.0, config.Sum.Rate; want != have {
+		t.Errorf("Sum.Rate: want %v, have %v", want, have)
+	}
+	if want, have := 0.5, config.Concat.BreakerTripRatio; want != have {
+		t.Errorf("Concat.BreakerTripRatio: want %v, have %v", want, have)
+	}
+	if err := config.Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+
+	config.Concat.BreakerTripRatio = 2
+	if err := config.Validate(); err == nil {
+		t.Error("Validate: want error for trip ratio 2, have none")
+	}
+}
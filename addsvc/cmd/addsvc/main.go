@@ -1,9 +1,16 @@
 package main
 
 import (
+	"errors"
 	"flag"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/metrics"
@@ -11,14 +18,41 @@ import (
 	stdopentracing "github.com/opentracing/opentracing-go"
 	stdprometheus "github.com/prometheus/client_golang/prometheus"
 	"golang.org/x/net/context"
+	"google.golang.org/grpc"
 
 	"github.com/peterbourgon/go-microservices/addsvc/pkg/endpoints"
+	addgrpc "github.com/peterbourgon/go-microservices/addsvc/pkg/grpc"
+	"github.com/peterbourgon/go-microservices/addsvc/pkg/grpc/pb"
 	addhttp "github.com/peterbourgon/go-microservices/addsvc/pkg/http"
 	"github.com/peterbourgon/go-microservices/addsvc/pkg/service"
+	"github.com/peterbourgon/go-microservices/addsvc/pkg/stringsvc"
+	"github.com/peterbourgon/go-microservices/pkg/health"
+	"github.com/peterbourgon/go-microservices/pkg/shutdown"
+	"github.com/peterbourgon/go-microservices/pkg/tracing"
 )
 
 func main() {
-	addr := flag.String("addr", ":8080", "HTTP listen address")
+	var (
+		addr     = flag.String("addr", ":8080", "HTTP listen address")
+		grpcAddr = flag.String("grpc.addr", ":8082", "gRPC listen address")
+		maxBody  = flag.Int64("body.max", addhttp.DefaultMaxBodySize, "maximum size of HTTP request bodies in bytes")
+		config   = endpoints.DefaultConfig()
+		tracer   = tracing.DefaultConfig("addsvc")
+
+		concatMax  = flag.Int("concat.max", service.DefaultMaxConcatSize, "maximum size of a concatenated string")
+		concatUnit = service.Bytes
+
+		stringsvcAddr    = flag.String("stringsvc.addr", "", "stringsvc base URL or host:port, to uppercase Concat results (empty to disable)")
+		stringsvcTimeout = flag.Duration("stringsvc.timeout", stringsvc.DefaultTimeout, "deadline for each call to stringsvc")
+
+		shutdownDelay   = flag.Duration("shutdown.delay", 0, "time to keep serving with readiness failing before draining")
+		shutdownTimeout = flag.Duration("shutdown.timeout", 10*time.Second, "deadline for draining in-flight requests")
+	)
+	config.Sum.Flags(flag.CommandLine, "sum")
+	config.Concat.Flags(flag.CommandLine, "concat")
+	flag.Var(&concatUnit, "concat.unit", "unit of the concat maximum size: bytes, runes, or graphemes")
+	flag.IntVar(&config.MaxCallers, "callers.max", config.MaxCallers, "maximum number of callers tracked by per-caller rate limiters; API keys are unauthenticated, so new ones evict the least recently seen callers")
+	tracer.Flags(flag.CommandLine)
 	flag.Parse()
 
 	var logger log.Logger
@@ -28,9 +62,29 @@ func main() {
 		logger = log.NewContext(logger).With("caller", log.DefaultCaller)
 	}
 
+	if err := parseEnv(flag.CommandLine, "ADDSVC_"); err != nil {
+		logger.Log("err", err)
+		os.Exit(1)
+	}
+	if err := config.Validate(); err != nil {
+		logger.Log("err", err)
+		os.Exit(1)
+	}
+	if *concatMax < 1 {
+		logger.Log("err", fmt.Sprintf("concat max must be at least 1, have %d", *concatMax))
+		os.Exit(1)
+	}
+
 	var trace stdopentracing.Tracer
+	var traceCloser io.Closer
 	{
-		trace = stdopentracing.GlobalTracer() // no-op
+		var err error
+		trace, traceCloser, err = tracing.New(tracer, logger)
+		if err != nil {
+			logger.Log("err", err)
+			os.Exit(1)
+		}
+		logger.Log("tracer", tracer.Backend, "addr", tracer.Addr, "sample", tracer.SampleRate)
 	}
 
 	// Our metrics are dependencies, here we create them.
@@ -61,10 +115,75 @@ func main() {
 		}, []string{"method", "success"})
 	}
 
-	svc := service.New(logger, ints, chars)
-	eps := endpoints.New(svc, logger, duration, trace)
-	mux := addhttp.NewHandler(context.Background(), eps, logger, trace)
+	options := []service.Option{service.MaxConcatSize(*concatMax), service.ConcatUnit(concatUnit)}
+	if *stringsvcAddr != "" {
+		uppercaser, err := stringsvc.New(*stringsvcAddr, logger, trace, stringsvc.Timeout(*stringsvcTimeout))
+		if err != nil {
+			logger.Log("err", err)
+			os.Exit(1)
+		}
+		logger.Log("stringsvc", *stringsvcAddr)
+		options = append(options, service.UppercaseConcat(uppercaser))
+	}
+	svc := service.New(logger, ints, chars, options...)
+	eps := endpoints.New(svc, config, logger, duration, trace)
+	ready := health.NewFlag(errors.New("shutting down"))
+	handler := addhttp.NewHandler(context.Background(), eps, logger, trace, addhttp.MaxBodySize(*maxBody), addhttp.Shutdown(ready))
+
+	httpServer := &http.Server{Addr: *addr, Handler: handler}
+	grpcServer := grpc.NewServer()
+	pb.RegisterAddServer(grpcServer, addgrpc.NewServer(context.Background(), eps, logger, trace))
+
+	// Both transports serve the same endpoints; whichever fails first, or an
+	// interrupt, terminates the process.
+	errc := make(chan error, 3)
+	go func() {
+		logger.Log("transport", "HTTP", "addr", *addr)
+		if err := httpServer.ListenAndServe(); err != http.ErrServerClosed {
+			errc <- err
+		}
+	}()
+	go func() {
+		ln, err := net.Listen("tcp", *grpcAddr)
+		if err != nil {
+			errc <- err
+			return
+		}
+		logger.Log("transport", "gRPC", "addr", *grpcAddr)
+		errc <- grpcServer.Serve(ln)
+	}()
+	go func() {
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
+		errc <- fmt.Errorf("%s", <-c)
+	}()
+	logger.Log("exit", <-errc)
 
-	logger.Log("transport", "HTTP", "addr", *addr)
-	logger.Log("exit", http.ListenAndServe(*addr, mux))
+	shutdown.Sequence{
+		Readiness: ready,
+		Delay:     *shutdownDelay,
+		Timeout:   *shutdownTimeout,
+		Transports: map[string]shutdown.DrainFunc{
+			"HTTP": httpServer.Shutdown,
+			"gRPC": func(ctx context.Context) error { return stopGRPC(ctx, grpcServer) },
+		},
+		Tracer: traceCloser,
+	}.Run(logger)
+}
+
+// stopGRPC gracefully stops the server, waiting for pending RPCs to finish. If
+// the context is done first, remaining RPCs are cancelled.
+func stopGRPC(ctx context.Context, s *grpc.Server) error {
+	done := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.Stop()
+		return ctx.Err()
+	}
 }
@@ -2,12 +2,15 @@ package main
 
 import (
 	"fmt"
-	"net/http"
 	"os"
-	"strings"
 	"testing"
 
+	"github.com/go-kit/kit/log"
+	"github.com/opentracing/opentracing-go"
 	"github.com/pact-foundation/pact-go/dsl"
+	"golang.org/x/net/context"
+
+	"github.com/peterbourgon/go-microservices/addsvc/pkg/stringsvc"
 )
 
 func TestPactStringsvcUppercase(t *testing.T) {
@@ -37,15 +40,17 @@ func TestPactStringsvcUppercase(t *testing.T) {
 		})
 
 	if err := pact.Verify(func() error {
-		u := fmt.Sprintf("http://localhost:%d/uppercase", pact.Server.Port)
-		req, err := http.NewRequest("POST", u, strings.NewReader(`{"s":"foo"}`))
+		c, err := stringsvc.New(fmt.Sprintf("localhost:%d", pact.Server.Port), log.NewNopLogger(), opentracing.GlobalTracer())
 		if err != nil {
 			return err
 		}
-		req.Header.Set("Content-Type", "application/json; charset=utf-8")
-		if _, err = http.DefaultClient.Do(req); err != nil {
+		v, err := c.Uppercase(context.Background(), "foo")
+		if err != nil {
 			return err
 		}
+		if want, have := "FOO", v; want != have {
+			return fmt.Errorf("want %q, have %q", want, have)
+		}
 		return nil
 	}); err != nil {
 		t.Fatal(err)
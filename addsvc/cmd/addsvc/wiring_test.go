@@ -1,25 +1,50 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
+	"unicode/utf8"
 
+	"github.com/go-kit/kit/endpoint"
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/metrics/discard"
+	"github.com/go-kit/kit/metrics/generic"
+	kitopentracing "github.com/go-kit/kit/tracing/opentracing"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/golang/protobuf/proto"
 	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/sony/gobreaker"
+	"github.com/ugorji/go/codec"
 	"golang.org/x/net/context"
+	"google.golang.org/grpc"
 
+	"github.com/peterbourgon/go-microservices/addsvc/pkg/client"
 	"github.com/peterbourgon/go-microservices/addsvc/pkg/endpoints"
+	addgrpc "github.com/peterbourgon/go-microservices/addsvc/pkg/grpc"
+	"github.com/peterbourgon/go-microservices/addsvc/pkg/grpc/pb"
 	addhttp "github.com/peterbourgon/go-microservices/addsvc/pkg/http"
 	"github.com/peterbourgon/go-microservices/addsvc/pkg/service"
+	"github.com/peterbourgon/go-microservices/addsvc/pkg/stringsvc"
+	"github.com/peterbourgon/go-microservices/pkg/health"
+	"github.com/peterbourgon/go-microservices/pkg/openapi"
+	"github.com/peterbourgon/go-microservices/pkg/tracing"
 )
 
 func TestWiring(t *testing.T) {
 	svc := service.New(log.NewNopLogger(), discard.NewCounter(), discard.NewCounter())
-	eps := endpoints.New(svc, log.NewNopLogger(), discard.NewHistogram(), opentracing.GlobalTracer())
+	eps := endpoints.New(svc, endpoints.DefaultConfig(), log.NewNopLogger(), discard.NewHistogram(), opentracing.GlobalTracer())
 	mux := addhttp.NewHandler(context.Background(), eps, log.NewNopLogger(), opentracing.GlobalTracer())
 	srv := httptest.NewServer(mux)
 	defer srv.Close()
@@ -38,3 +63,934 @@ func TestWiring(t *testing.T) {
 		}
 	}
 }
+
+func TestQueryAndFormWiring(t *testing.T) {
+	svc := service.New(log.NewNopLogger(), discard.NewCounter(), discard.NewCounter())
+	eps := endpoints.New(svc, endpoints.DefaultConfig(), log.NewNopLogger(), discard.NewHistogram(), opentracing.GlobalTracer())
+	mux := addhttp.NewHandler(context.Background(), eps, log.NewNopLogger(), opentracing.GlobalTracer())
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	const form = "application/x-www-form-urlencoded"
+	for _, testcase := range []struct {
+		method, url, contentType, body string
+		code                           int
+		want                           string
+	}{
+		{"GET", "/sum?a=1&b=2", "", "", http.StatusOK, `{"v":3}`},
+		{"GET", "/concat?a=x&b=%C3%BF", "", "", http.StatusOK, `{"v":"xÿ"}`},
+		{"GET", "/concat?a=&b=y", "", "", http.StatusOK, `{"v":"y"}`},
+		{"POST", "/sum", form, "a=-4&b=6", http.StatusOK, `{"v":2}`},
+		{"POST", "/concat", form, "a=1&b=2", http.StatusOK, `{"v":"12"}`},
+		{"GET", "/sum?a=1&b=two", "", "", http.StatusBadRequest, `{"error":"Decode: invalid request: b: must be an integer, not \"two\"","code":"invalid_request"`},
+		{"GET", "/sum?a=1", "", "", http.StatusBadRequest, `{"error":"Decode: invalid request: b: required","code":"invalid_request"`},
+		{"POST", "/sum", form, "a=1.5&b=2", http.StatusBadRequest, `{"error":"Decode: invalid request: a: must be an integer, not \"1.5\"","code":"invalid_request"`},
+		{"PUT", "/sum", "application/json", `{"a":1,"b":2}`, http.StatusMethodNotAllowed, `{"error":"Decode: method not allowed; allowed: GET, POST","code":"method_not_allowed"`},
+		{"DELETE", "/concat?a=1&b=2", "", "", http.StatusMethodNotAllowed, `{"error":"Decode: method not allowed; allowed: GET, POST","code":"method_not_allowed"`},
+	} {
+		req, _ := http.NewRequest(testcase.method, srv.URL+testcase.url, strings.NewReader(testcase.body))
+		if testcase.contentType != "" {
+			req.Header.Set("Content-Type", testcase.contentType)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if want, have := testcase.code, resp.StatusCode; want != have {
+			t.Errorf("%s %s: want %d, have %d", testcase.method, testcase.url, want, have)
+		}
+		if want, have := testcase.want, strings.TrimSpace(string(body)); !strings.HasPrefix(have, want) {
+			t.Errorf("%s %s: want %s, have %s", testcase.method, testcase.url, want, have)
+		}
+		if resp.StatusCode == http.StatusMethodNotAllowed {
+			if want, have := "GET, POST", resp.Header.Get("Allow"); want != have {
+				t.Errorf("%s %s: Allow: want %q, have %q", testcase.method, testcase.url, want, have)
+			}
+		}
+	}
+}
+
+func TestGRPCWiring(t *testing.T) {
+	svc := service.New(log.NewNopLogger(), discard.NewCounter(), discard.NewCounter())
+	eps := endpoints.New(svc, endpoints.DefaultConfig(), log.NewNopLogger(), discard.NewHistogram(), opentracing.GlobalTracer())
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	pb.RegisterAddServer(srv, addgrpc.NewServer(context.Background(), eps, log.NewNopLogger(), opentracing.GlobalTracer()))
+	go srv.Serve(ln)
+	defer srv.Stop()
+
+	conn, err := grpc.Dial(ln.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := pb.NewAddClient(conn)
+
+	sum, err := client.Sum(context.Background(), &pb.SumRequest{A: 1, B: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := int64(3), sum.V; want != have {
+		t.Errorf("Sum: want %d, have %d", want, have)
+	}
+
+	concat, err := client.Concat(context.Background(), &pb.ConcatRequest{A: "1", B: "2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "12", concat.V; want != have {
+		t.Errorf("Concat: want %q, have %q", want, have)
+	}
+
+	concat, err = client.Concat(context.Background(), &pb.ConcatRequest{A: "0123456789", B: "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "result exceeds maximum size: 11 bytes, limit 10", concat.Err; want != have {
+		t.Errorf("Concat: want err %q, have %q", want, have)
+	}
+}
+
+func TestClientWiring(t *testing.T) {
+	svc := service.New(log.NewNopLogger(), discard.NewCounter(), discard.NewCounter())
+	eps := endpoints.New(svc, endpoints.DefaultConfig(), log.NewNopLogger(), discard.NewHistogram(), opentracing.GlobalTracer())
+	mux := addhttp.NewHandler(context.Background(), eps, log.NewNopLogger(), opentracing.GlobalTracer())
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	c, err := client.New(srv.URL, log.NewNopLogger(), opentracing.GlobalTracer())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v, err := c.Sum(context.Background(), 1, 2); err != nil || v != 3 {
+		t.Errorf("Sum(1, 2): want 3, <nil>; have %d, %v", v, err)
+	}
+	if v, err := c.Concat(context.Background(), "1", "2"); err != nil || v != "12" {
+		t.Errorf("Concat(1, 2): want 12, <nil>; have %q, %v", v, err)
+	}
+	if _, err := c.Concat(context.Background(), "0123456789", "x"); err != service.ErrMaxSizeExceeded {
+		t.Errorf("Concat: want %v, have %v", service.ErrMaxSizeExceeded, err)
+	}
+}
+
+func TestLoadBalancedClientWiring(t *testing.T) {
+	svc := service.New(log.NewNopLogger(), discard.NewCounter(), discard.NewCounter())
+	eps := endpoints.New(svc, endpoints.DefaultConfig(), log.NewNopLogger(), discard.NewHistogram(), opentracing.GlobalTracer())
+	mux := addhttp.NewHandler(context.Background(), eps, log.NewNopLogger(), opentracing.GlobalTracer())
+	up := httptest.NewServer(mux)
+	defer up.Close()
+	down := httptest.NewServer(mux)
+	down.Close() // requests to this instance fail, and should be retried
+
+	c, err := client.NewStatic([]string{up.URL, down.URL}, 3, time.Second, log.NewNopLogger(), opentracing.GlobalTracer())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 4; i++ {
+		if v, err := c.Concat(context.Background(), "1", "2"); err != nil || v != "12" {
+			t.Errorf("Concat(1, 2) #%d: want 12, <nil>; have %q, %v", i, v, err)
+		}
+	}
+	if _, err := c.Concat(context.Background(), "0123456789", "x"); err != service.ErrMaxSizeExceeded {
+		t.Errorf("Concat: want %v, have %v", service.ErrMaxSizeExceeded, err)
+	}
+}
+
+func TestLoadBalancedClientOverloadWiring(t *testing.T) {
+	svc := service.New(log.NewNopLogger(), discard.NewCounter(), discard.NewCounter())
+	eps := endpoints.New(svc, endpoints.DefaultConfig(), log.NewNopLogger(), discard.NewHistogram(), opentracing.GlobalTracer())
+	up := httptest.NewServer(addhttp.NewHandler(context.Background(), eps, log.NewNopLogger(), opentracing.GlobalTracer()))
+	defer up.Close()
+	var overloadedCalls int64
+	overloaded := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&overloadedCalls, 1)
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprint(w, `{"error":"circuit breaker is open","code":"circuit_open"}`)
+	}))
+	defer overloaded.Close()
+
+	c, err := client.NewStatic([]string{overloaded.URL, up.URL}, 3, time.Second, log.NewNopLogger(), opentracing.GlobalTracer())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every request should succeed, whichever instance it's sent to first.
+	for i := 0; i < 4; i++ {
+		if v, err := c.Concat(context.Background(), "1", "2"); err != nil || v != "12" {
+			t.Errorf("Concat(1, 2) #%d: want 12, <nil>; have %q, %v", i, v, err)
+		}
+	}
+	if atomic.LoadInt64(&overloadedCalls) == 0 {
+		t.Error("want some requests sent to the overloaded instance, have none")
+	}
+
+	// Business errors are still returned as such, without being retried.
+	if _, err := c.Concat(context.Background(), "0123456789", "x"); err != service.ErrMaxSizeExceeded {
+		t.Errorf("Concat: want %v, have %v", service.ErrMaxSizeExceeded, err)
+	}
+}
+
+func TestCallerRateLimitWiring(t *testing.T) {
+	config := endpoints.DefaultConfig()
+	config.Concat.CallerRate, config.Concat.CallerBurst = 1, 1
+	svc := service.New(log.NewNopLogger(), discard.NewCounter(), discard.NewCounter())
+	eps := endpoints.New(svc, config, log.NewNopLogger(), discard.NewHistogram(), opentracing.GlobalTracer())
+	mux := addhttp.NewHandler(context.Background(), eps, log.NewNopLogger(), opentracing.GlobalTracer())
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	for _, testcase := range []struct {
+		key        string
+		code       int
+		retryAfter string
+	}{
+		{"alice", http.StatusOK, ""},
+		{"alice", http.StatusTooManyRequests, "1"},
+		{"bob", http.StatusOK, ""},
+	} {
+		req, _ := http.NewRequest("POST", srv.URL+"/concat", strings.NewReader(`{"a":"1","b":"2"}`))
+		req.Header.Set("X-API-Key", testcase.key)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if want, have := testcase.code, resp.StatusCode; want != have {
+			t.Errorf("%s: want %d, have %d", testcase.key, want, have)
+		}
+		if want, have := testcase.retryAfter, resp.Header.Get("Retry-After"); want != have {
+			t.Errorf("%s: want Retry-After %q, have %q", testcase.key, want, have)
+		}
+	}
+}
+
+func TestRateLimitChargesBothOrNeitherWiring(t *testing.T) {
+	config := endpoints.DefaultConfig()
+	config.Concat.Rate, config.Concat.Burst = 10, 1
+	config.Concat.CallerRate, config.Concat.CallerBurst = 0.001, 2
+	svc := service.New(log.NewNopLogger(), discard.NewCounter(), discard.NewCounter())
+	eps := endpoints.New(svc, config, log.NewNopLogger(), discard.NewHistogram(), opentracing.GlobalTracer())
+	mux := addhttp.NewHandler(context.Background(), eps, log.NewNopLogger(), opentracing.GlobalTracer())
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	// alice's second request is rejected by the overall limit, so it mustn't
+	// spend her last token: once the overall bucket refills, her third
+	// request succeeds.
+	for i, testcase := range []struct {
+		wait time.Duration
+		code int
+	}{
+		{0, http.StatusOK},
+		{0, http.StatusTooManyRequests},
+		{200 * time.Millisecond, http.StatusOK},
+	} {
+		time.Sleep(testcase.wait)
+		req, _ := http.NewRequest("POST", srv.URL+"/concat", strings.NewReader(`{"a":"1","b":"2"}`))
+		req.Header.Set("X-API-Key", "alice")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if want, have := testcase.code, resp.StatusCode; want != have {
+			t.Errorf("request %d: want %d, have %d", i+1, want, have)
+		}
+	}
+}
+
+func TestRejectionWiring(t *testing.T) {
+	config := endpoints.DefaultConfig()
+	config.Sum.Rate, config.Sum.Burst = 0.001, 1 // one request, then nothing
+	svc := service.New(log.NewNopLogger(), discard.NewCounter(), discard.NewCounter())
+	eps := endpoints.New(svc, config, log.NewNopLogger(), discard.NewHistogram(), opentracing.GlobalTracer())
+	mux := addhttp.NewHandler(context.Background(), eps, log.NewNopLogger(), opentracing.GlobalTracer())
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	// Rate limited requests are rejected before they reach the circuit
+	// breaker, so however many there are, they never open it. The breaker
+	// would trip after more than 5 consecutive failures.
+	for i := 0; i < 20; i++ {
+		wantCode, wantRetryAfter := http.StatusTooManyRequests, "1000" // until the next token
+		if i == 0 {
+			wantCode, wantRetryAfter = http.StatusOK, ""
+		}
+		resp, err := http.Post(srv.URL+"/sum", "application/json", strings.NewReader(`{"a":1,"b":2}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if want, have := wantCode, resp.StatusCode; want != have {
+			t.Errorf("request %d: want %d, have %d", i+1, want, have)
+		}
+		if want, have := wantRetryAfter, resp.Header.Get("Retry-After"); want != have {
+			t.Errorf("request %d: want Retry-After %q, have %q", i+1, want, have)
+		}
+	}
+
+	for name, cb := range eps.Breakers {
+		if want, have := gobreaker.StateClosed, cb.State(); want != have {
+			t.Errorf("%s breaker: want %v, have %v", name, want, have)
+		}
+	}
+	resp, err := http.Get(srv.URL + "/readyz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if want, have := http.StatusOK, resp.StatusCode; want != have {
+		t.Errorf("/readyz: want %d, have %d", want, have)
+	}
+}
+
+func TestHealthWiring(t *testing.T) {
+	for _, testcase := range []struct {
+		name            string
+		setup           func(endpoints.Endpoints, *health.Flag)
+		healthz, readyz int
+	}{
+		{"serving", func(endpoints.Endpoints, *health.Flag) {}, http.StatusOK, http.StatusOK},
+		{"breaker open", func(eps endpoints.Endpoints, _ *health.Flag) {
+			for i := 0; i < 6; i++ { // trips after more than 5 consecutive failures
+				eps.Breakers["Sum"].Execute(func() (interface{}, error) { return nil, errors.New("fail") })
+			}
+		}, http.StatusOK, http.StatusServiceUnavailable},
+		{"shutting down", func(_ endpoints.Endpoints, shutdown *health.Flag) {
+			shutdown.Fail()
+		}, http.StatusOK, http.StatusServiceUnavailable},
+	} {
+		svc := service.New(log.NewNopLogger(), discard.NewCounter(), discard.NewCounter())
+		eps := endpoints.New(svc, endpoints.DefaultConfig(), log.NewNopLogger(), discard.NewHistogram(), opentracing.GlobalTracer())
+		shutdown := health.NewFlag(errors.New("shutting down"))
+		h := addhttp.NewHandler(context.Background(), eps, log.NewNopLogger(), opentracing.GlobalTracer(), addhttp.Shutdown(shutdown))
+		testcase.setup(eps, shutdown)
+
+		for path, want := range map[string]int{"/healthz": testcase.healthz, "/readyz": testcase.readyz} {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+			if have := rec.Code; want != have {
+				t.Errorf("%s: %s: want %d, have %d", testcase.name, path, want, have)
+			}
+		}
+	}
+}
+
+func TestRetryAfterWiring(t *testing.T) {
+	config := endpoints.DefaultConfig()
+	config.Sum.Rate, config.Sum.Burst = 0.25, 1
+	config.Concat.CallerRate, config.Concat.CallerBurst = 0.4, 1
+	config.Concat.BreakerTimeout = 30 * time.Second
+	svc := service.New(log.NewNopLogger(), discard.NewCounter(), discard.NewCounter())
+	eps := endpoints.New(svc, config, log.NewNopLogger(), discard.NewHistogram(), opentracing.GlobalTracer())
+	mux := addhttp.NewHandler(context.Background(), eps, log.NewNopLogger(), opentracing.GlobalTracer())
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	eps.ConcatEndpoint = func(context.Context, interface{}) (interface{}, error) {
+		return nil, gobreaker.ErrOpenState
+	}
+	open := httptest.NewServer(addhttp.NewHandler(context.Background(), eps, log.NewNopLogger(), opentracing.GlobalTracer()))
+	defer open.Close()
+
+	for _, testcase := range []struct {
+		url, body  string
+		code       int
+		retryAfter string
+	}{
+		{srv.URL + "/sum", `{"a":1,"b":2}`, http.StatusOK, ""},
+		{srv.URL + "/sum", `{"a":1,"b":2}`, http.StatusTooManyRequests, "4"}, // 1/0.25s
+		{srv.URL + "/concat", `{"a":"1","b":"2"}`, http.StatusOK, ""},
+		{srv.URL + "/concat", `{"a":"1","b":"2"}`, http.StatusTooManyRequests, "3"}, // 1/0.4s, rounded up
+		{open.URL + "/concat", `{"a":"1","b":"2"}`, http.StatusServiceUnavailable, "30"},
+	} {
+		resp, err := http.Post(testcase.url, "application/json", strings.NewReader(testcase.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if want, have := testcase.code, resp.StatusCode; want != have {
+			t.Errorf("%s: want %d, have %d", testcase.url, want, have)
+		}
+		if want, have := testcase.retryAfter, resp.Header.Get("Retry-After"); want != have {
+			t.Errorf("%s: want Retry-After %q, have %q", testcase.url, want, have)
+		}
+	}
+}
+
+func TestErrorEnvelopeWiring(t *testing.T) {
+	svc := service.New(log.NewNopLogger(), discard.NewCounter(), discard.NewCounter())
+	eps := endpoints.New(svc, endpoints.DefaultConfig(), log.NewNopLogger(), discard.NewHistogram(), opentracing.GlobalTracer())
+	mux := addhttp.NewHandler(context.Background(), eps, log.NewNopLogger(), opentracing.GlobalTracer())
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	for _, testcase := range []struct {
+		url, body, requestID string
+		code                 int
+		errcode              string
+	}{
+		{srv.URL + "/sum", `{"a":0,"b":0}`, "abc123", http.StatusBadRequest, "two_zeroes"},
+		{srv.URL + "/concat", `{"a":"0123456789","b":"x"}`, "", http.StatusBadRequest, "max_size_exceeded"},
+		{srv.URL + "/concat", `{"a":`, "", http.StatusBadRequest, "decode_error"},
+	} {
+		req, _ := http.NewRequest("POST", testcase.url, strings.NewReader(testcase.body))
+		if testcase.requestID != "" {
+			req.Header.Set("X-Request-ID", testcase.requestID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var envelope struct {
+			Error     string `json:"error"`
+			Code      string `json:"code"`
+			RequestID string `json:"request_id"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&envelope)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("%s %s: %v", testcase.url, testcase.body, err)
+		}
+		if want, have := testcase.code, resp.StatusCode; want != have {
+			t.Errorf("%s %s: want status %d, have %d", testcase.url, testcase.body, want, have)
+		}
+		if want, have := testcase.errcode, envelope.Code; want != have {
+			t.Errorf("%s %s: want code %q, have %q", testcase.url, testcase.body, want, have)
+		}
+		if envelope.Error == "" {
+			t.Errorf("%s %s: want error message, have none", testcase.url, testcase.body)
+		}
+		if envelope.RequestID == "" || envelope.RequestID != resp.Header.Get("X-Request-ID") {
+			t.Errorf("%s %s: want matching request IDs, have %q in body and %q in header", testcase.url, testcase.body, envelope.RequestID, resp.Header.Get("X-Request-ID"))
+		}
+		if testcase.requestID != "" && testcase.requestID != envelope.RequestID {
+			t.Errorf("%s %s: want request ID %q, have %q", testcase.url, testcase.body, testcase.requestID, envelope.RequestID)
+		}
+	}
+}
+
+func TestBatchWiring(t *testing.T) {
+	svc := service.New(log.NewNopLogger(), discard.NewCounter(), discard.NewCounter())
+	eps := endpoints.New(svc, endpoints.DefaultConfig(), log.NewNopLogger(), discard.NewHistogram(), opentracing.GlobalTracer())
+	mux := addhttp.NewHandler(context.Background(), eps, log.NewNopLogger(), opentracing.GlobalTracer())
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	batch := func(n int) string {
+		return "[" + strings.TrimSuffix(strings.Repeat(`{"a":1,"b":2},`, n), ",") + "]"
+	}
+	for _, testcase := range []struct {
+		url, body string
+		code      int
+		want      string
+	}{
+		{srv.URL + "/concat/batch", `[{"a":"1","b":"2"},{"a":"0123456789","b":"x"}]`, http.StatusOK, `[{"v":"12"},{"error":"result exceeds maximum size: 11 bytes, limit 10","code":"max_size_exceeded"}]`},
+		{srv.URL + "/sum/batch", `[{"a":1,"b":2},{"a":0,"b":0},{"a":0,"b":3}]`, http.StatusOK, `[{"v":3},{"error":"can't sum two zeroes","code":"two_zeroes"},{"v":3}]`},
+		{srv.URL + "/sum/batch", batch(50), http.StatusOK, ""},
+		{srv.URL + "/sum/batch", batch(101), http.StatusRequestEntityTooLarge, ""}, // more than Sum's burst
+	} {
+		resp, err := http.Post(testcase.url, "application/json", strings.NewReader(testcase.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if want, have := testcase.code, resp.StatusCode; want != have {
+			t.Errorf("%s %s: want %d, have %d: %s", testcase.url, testcase.body, want, have, strings.TrimSpace(string(body)))
+		}
+		if testcase.want == "" {
+			continue
+		}
+		if want, have := testcase.want, strings.TrimSpace(string(body)); want != have {
+			t.Errorf("%s %s: want %s, have %s", testcase.url, testcase.body, want, have)
+		}
+	}
+}
+
+func TestBatchRateLimitWiring(t *testing.T) {
+	config := endpoints.DefaultConfig()
+	config.Sum.Rate, config.Sum.Burst = 0.001, 4 // four items, then nothing
+	svc := service.New(log.NewNopLogger(), discard.NewCounter(), discard.NewCounter())
+	eps := endpoints.New(svc, config, log.NewNopLogger(), discard.NewHistogram(), opentracing.GlobalTracer())
+	mux := addhttp.NewHandler(context.Background(), eps, log.NewNopLogger(), opentracing.GlobalTracer())
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	for _, testcase := range []struct {
+		url, body string
+		code      int
+		want      string
+	}{
+		{srv.URL + "/sum/batch", `[{"a":1,"b":2},{"a":0,"b":0},{"a":0,"b":3}]`, http.StatusOK, `[{"v":3},{"error":"can't sum two zeroes","code":"two_zeroes"},{"v":3}]`},
+		{srv.URL + "/sum/batch", `[{"a":1,"b":2},{"a":1,"b":2}]`, http.StatusTooManyRequests, ""}, // only one token left
+		{srv.URL + "/sum/batch", `[{"a":1,"b":2},{"a":1,"b":2},{"a":1,"b":2},{"a":1,"b":2},{"a":1,"b":2}]`, http.StatusRequestEntityTooLarge, ""},
+		{srv.URL + "/sum", `{"a":1,"b":2}`, http.StatusOK, `{"v":3}`}, // the last token
+	} {
+		resp, err := http.Post(testcase.url, "application/json", strings.NewReader(testcase.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if want, have := testcase.code, resp.StatusCode; want != have {
+			t.Errorf("%s %s: want %d, have %d", testcase.url, testcase.body, want, have)
+		}
+		if testcase.want == "" {
+			continue
+		}
+		if want, have := testcase.want, strings.TrimSpace(string(body)); want != have {
+			t.Errorf("%s %s: want %s, have %s", testcase.url, testcase.body, want, have)
+		}
+	}
+}
+
+func TestSumBigWiring(t *testing.T) {
+	svc := service.New(log.NewNopLogger(), discard.NewCounter(), discard.NewCounter())
+	eps := endpoints.New(svc, endpoints.DefaultConfig(), log.NewNopLogger(), discard.NewHistogram(), opentracing.GlobalTracer())
+	mux := addhttp.NewHandler(context.Background(), eps, log.NewNopLogger(), opentracing.GlobalTracer())
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/sum/big", "application/json", strings.NewReader(`{"a":"99999999999999999999","b":"1"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if want, have := `{"v":"100000000000000000000"}`, strings.TrimSpace(string(body)); want != have {
+		t.Errorf("want %s, have %s", want, have)
+	}
+
+	c, err := client.New(srv.URL, log.NewNopLogger(), opentracing.GlobalTracer())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.SumBig(context.Background(), "12x", "1"); err != service.ErrInvalidNumber {
+		t.Errorf("SumBig: want %v, have %v", service.ErrInvalidNumber, err)
+	}
+}
+
+func TestSumManyWiring(t *testing.T) {
+	ints := generic.NewCounter("ints")
+	svc := service.New(log.NewNopLogger(), ints, discard.NewCounter())
+	eps := endpoints.New(svc, endpoints.DefaultConfig(), log.NewNopLogger(), discard.NewHistogram(), opentracing.GlobalTracer())
+	mux := addhttp.NewHandler(context.Background(), eps, log.NewNopLogger(), opentracing.GlobalTracer())
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	for _, testcase := range []struct {
+		contentType, body string
+		want              string
+	}{
+		{"application/json", `{"operands":[1,2,3]}`, `{"v":6}`},
+		{"application/x-ndjson", "1\n2\n3\n4\n", `{"v":10}`},
+		{"application/x-ndjson", "0\n0\n0\n", `{"error":"can't sum two zeroes","code":"two_zeroes"`},
+		{"application/x-ndjson", "9223372036854775807\n1\n-1\n", `{"error":"integer overflow","code":"int_overflow"`},
+		{"application/x-ndjson", "1\n\"two\"\n", `{"error":"Decode: invalid request: operands[1]: must be int, not string","code":"invalid_request"`},
+		{"application/x-ndjson", strings.Repeat("1\n", 200), `{"v":200}`}, // one token, however many operands
+		{"application/x-ndjson", strings.Repeat("1\n", addhttp.DefaultMaxBodySize/2+1), `{"error":"Decode: request body too large","code":"body_too_large"`},
+	} {
+		resp, err := http.Post(srv.URL+"/sum/many", testcase.contentType, strings.NewReader(testcase.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if want, have := testcase.want, strings.TrimSpace(string(body)); !strings.HasPrefix(have, want) {
+			t.Errorf("%q: want %s, have %s", testcase.body, want, have)
+		}
+	}
+	if want, have := 207.0, ints.Value(); want != have {
+		t.Errorf("ints: want %v, have %v", want, have)
+	}
+
+	c, err := client.New(srv.URL, log.NewNopLogger(), opentracing.GlobalTracer())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := c.SumMany(context.Background(), []int{5, -2, 7}); err != nil || v != 10 {
+		t.Errorf("SumMany: want 10, have %d (%v)", v, err)
+	}
+	if _, err := c.SumMany(context.Background(), nil); err != service.ErrTwoZeroes {
+		t.Errorf("SumMany: want %v, have %v", service.ErrTwoZeroes, err)
+	}
+}
+
+func TestConcatLimitWiring(t *testing.T) {
+	for _, testcase := range []struct {
+		unit  service.Unit
+		limit int
+		a, b  string
+		want  string
+	}{
+		{service.Bytes, 10, "héllo", "wörld", `{"error":"result exceeds maximum size: 12 bytes, limit 10","code":"max_size_exceeded"`},
+		{service.Runes, 10, "héllo", "wörld", `{"v":"héllowörld"}`},
+		{service.Runes, 6, "👩‍👩‍👧", "👩‍👩‍👧", `{"error":"result exceeds maximum size: 10 runes, limit 6","code":"max_size_exceeded"`},
+		{service.Graphemes, 6, "👩‍👩‍👧", "👩‍👩‍👧", `{"v":"👩‍👩‍👧👩‍👩‍👧"}`},
+		{service.Graphemes, 6, "🇨🇦e\u0301", "abcde", `{"error":"result exceeds maximum size: 7 graphemes, limit 6","code":"max_size_exceeded"`},
+	} {
+		chars := generic.NewCounter("chars")
+		svc := service.New(log.NewNopLogger(), discard.NewCounter(), chars, service.MaxConcatSize(testcase.limit), service.ConcatUnit(testcase.unit))
+		eps := endpoints.New(svc, endpoints.DefaultConfig(), log.NewNopLogger(), discard.NewHistogram(), opentracing.GlobalTracer())
+		srv := httptest.NewServer(addhttp.NewHandler(context.Background(), eps, log.NewNopLogger(), opentracing.GlobalTracer()))
+
+		req := fmt.Sprintf(`{"a":"%s","b":"%s"}`, testcase.a, testcase.b)
+		resp, err := http.Post(srv.URL+"/concat", "application/json", strings.NewReader(req))
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		srv.Close()
+		if want, have := testcase.want, strings.TrimSpace(string(body)); !strings.HasPrefix(have, want) {
+			t.Errorf("%s: %s: want %s, have %s", testcase.unit, req, want, have)
+		}
+		if resp.StatusCode == http.StatusOK {
+			if want, have := float64(utf8.RuneCountInString(testcase.a+testcase.b)), chars.Value(); want != have {
+				t.Errorf("%s: %s: chars: want %v, have %v", testcase.unit, req, want, have)
+			}
+		}
+	}
+}
+
+func TestContentNegotiationWiring(t *testing.T) {
+	svc := service.New(log.NewNopLogger(), discard.NewCounter(), discard.NewCounter())
+	eps := endpoints.New(svc, endpoints.DefaultConfig(), log.NewNopLogger(), discard.NewHistogram(), opentracing.GlobalTracer())
+	mux := addhttp.NewHandler(context.Background(), eps, log.NewNopLogger(), opentracing.GlobalTracer())
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	post := func(path, contentType, accept string, body []byte) *http.Response {
+		req, _ := http.NewRequest("POST", srv.URL+path, bytes.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	// msgpack in, msgpack out, with the same field names as JSON.
+	var mh codec.MsgpackHandle
+	var buf []byte
+	codec.NewEncoderBytes(&buf, &mh).MustEncode(map[string]string{"a": "1", "b": "2"})
+	resp := post("/concat", "application/msgpack", "application/msgpack", buf)
+	var concat map[string]string
+	if err := codec.NewDecoder(resp.Body, &mh).Decode(&concat); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if want, have := "application/msgpack", resp.Header.Get("Content-Type"); want != have {
+		t.Errorf("msgpack: Content-Type: want %q, have %q", want, have)
+	}
+	if want, have := "12", concat["v"]; want != have {
+		t.Errorf("msgpack: want %q, have %q", want, have)
+	}
+
+	// protobuf in, JSON out, and vice versa.
+	buf, _ = proto.Marshal(&pb.SumRequest{A: 1, B: 2})
+	resp = post("/sum", "application/x-protobuf", "application/json", buf)
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if want, have := `{"v":3}`, strings.TrimSpace(string(body)); want != have {
+		t.Errorf("protobuf to JSON: want %s, have %s", want, have)
+	}
+	resp = post("/sum", "application/json", "application/x-protobuf;q=0.9, text/html", []byte(`{"a":0,"b":0}`))
+	body, _ = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	var e pb.Error
+	if err := proto.Unmarshal(body, &e); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := http.StatusBadRequest, resp.StatusCode; want != have {
+		t.Errorf("JSON to protobuf: want %d, have %d", want, have)
+	}
+	if want, have := "two_zeroes", e.Code; want != have {
+		t.Errorf("JSON to protobuf: want code %q, have %q", want, have)
+	}
+
+	// The most specific media range wins, so an explicit q=0 rules a type out,
+	// even alongside a wildcard.
+	for _, testcase := range []struct {
+		accept, want string
+	}{
+		{"*/*, application/json;q=0", "application/msgpack"},
+		{"application/json;q=0, application/*;q=0.5, application/x-protobuf", "application/x-protobuf"},
+		{"application/*;q=0.5, */*;q=0.9", "application/json; charset=utf-8"}, // application/* is more specific
+	} {
+		resp := post("/sum", "application/json", testcase.accept, []byte(`{"a":1,"b":2}`))
+		resp.Body.Close()
+		if want, have := testcase.want, resp.Header.Get("Content-Type"); want != have {
+			t.Errorf("Accept %q: want %q, have %q", testcase.accept, want, have)
+		}
+	}
+
+	// Unsupported types are rejected, with the envelope in JSON.
+	for _, testcase := range []struct {
+		path, contentType, accept string
+		code                      int
+		errcode                   string
+	}{
+		{"/sum", "text/plain", "", http.StatusUnsupportedMediaType, "unsupported_media_type"},
+		{"/sum", "application/json", "text/html, application/xml;q=0.5", http.StatusNotAcceptable, "not_acceptable"},
+		{"/sum", "application/json", "application/msgpack;q=0", http.StatusNotAcceptable, "not_acceptable"},
+		{"/sum/batch", "application/x-protobuf", "", http.StatusUnsupportedMediaType, "unsupported_media_type"},
+		{"/concat/batch", "application/json", "application/x-protobuf", http.StatusNotAcceptable, "not_acceptable"},
+	} {
+		resp := post(testcase.path, testcase.contentType, testcase.accept, []byte(`{}`))
+		var e struct{ Code string }
+		json.NewDecoder(resp.Body).Decode(&e)
+		resp.Body.Close()
+		if want, have := testcase.code, resp.StatusCode; want != have {
+			t.Errorf("%s %s %s: want %d, have %d", testcase.path, testcase.contentType, testcase.accept, want, have)
+		}
+		if want, have := testcase.errcode, e.Code; want != have {
+			t.Errorf("%s %s %s: want code %q, have %q", testcase.path, testcase.contentType, testcase.accept, want, have)
+		}
+	}
+}
+
+func TestStrictDecodingWiring(t *testing.T) {
+	svc := service.New(log.NewNopLogger(), discard.NewCounter(), discard.NewCounter())
+	eps := endpoints.New(svc, endpoints.DefaultConfig(), log.NewNopLogger(), discard.NewHistogram(), opentracing.GlobalTracer())
+	mux := addhttp.NewHandler(context.Background(), eps, log.NewNopLogger(), opentracing.GlobalTracer(), addhttp.MaxBodySize(64))
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	type field struct{ Field, Error string }
+	for _, testcase := range []struct {
+		url, body string
+		code      int
+		errcode   string
+		fields    []field
+	}{
+		{"/sum", `{"a":1,"b":2}`, http.StatusOK, "", nil},
+		{"/sum", `{"a":0,"b":5}`, http.StatusOK, "", nil},
+		{"/sum", `{"a":"x","b":2}`, http.StatusBadRequest, "invalid_request", []field{{"a", "must be int, not string"}}},
+		{"/sum", `{"a":1,"b":2,"c":3}`, http.StatusBadRequest, "invalid_request", []field{{"c", "unknown field"}}},
+		{"/sum", `{"a":1}`, http.StatusBadRequest, "invalid_request", []field{{"b", "required"}}},
+		{"/sum", `{}`, http.StatusBadRequest, "invalid_request", []field{{"a", "required"}, {"b", "required"}}},
+		{"/sum", `{"a":1,"b":2} {"a":3}`, http.StatusBadRequest, "decode_error", nil},
+		{"/sum", `{"a":1,"b":2}` + strings.Repeat(" ", 64), http.StatusRequestEntityTooLarge, "body_too_large", nil},
+		{"/sum/many", `{}`, http.StatusBadRequest, "invalid_request", []field{{"operands", "required"}}},
+		{"/sum/batch", `[{"a":1,"b":2},{"b":2}]`, http.StatusBadRequest, "invalid_request", []field{{"[1].a", "required"}}},
+		{"/concat", `{"a":"x","b":""}`, http.StatusOK, "", nil},
+	} {
+		resp, err := http.Post(srv.URL+testcase.url, "application/json", strings.NewReader(testcase.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var e struct {
+			Code   string
+			Fields []field
+		}
+		json.NewDecoder(resp.Body).Decode(&e)
+		resp.Body.Close()
+		if want, have := testcase.code, resp.StatusCode; want != have {
+			t.Errorf("%s %s: want %d, have %d", testcase.url, testcase.body, want, have)
+		}
+		if want, have := testcase.errcode, e.Code; want != have {
+			t.Errorf("%s %s: want code %q, have %q", testcase.url, testcase.body, want, have)
+		}
+		if want, have := fmt.Sprint(testcase.fields), fmt.Sprint(e.Fields); want != have {
+			t.Errorf("%s %s: want fields %s, have %s", testcase.url, testcase.body, want, have)
+		}
+	}
+}
+
+func TestOpenAPIWiring(t *testing.T) {
+	svc := service.New(log.NewNopLogger(), discard.NewCounter(), discard.NewCounter())
+	eps := endpoints.New(svc, endpoints.DefaultConfig(), log.NewNopLogger(), discard.NewHistogram(), opentracing.GlobalTracer())
+	mux := addhttp.NewHandler(context.Background(), eps, log.NewNopLogger(), opentracing.GlobalTracer())
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/openapi.json", nil))
+	if want, have := http.StatusOK, rec.Code; want != have {
+		t.Fatalf("want %d, have %d", want, have)
+	}
+	var served openapi.Document
+	if err := json.NewDecoder(rec.Body).Decode(&served); err != nil {
+		t.Fatal(err)
+	}
+	var routes []string
+	for path := range addhttp.Routes(context.Background(), eps, log.NewNopLogger(), opentracing.GlobalTracer()) {
+		routes = append(routes, path)
+	}
+	if err := openapi.Verify(mux, &served, routes...); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTracingWiring(t *testing.T) {
+	c := tracing.DefaultConfig("addsvc")
+	c.Backend = tracing.Memory
+	trace, _, err := tracing.New(c, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := trace.(*mocktracer.MockTracer)
+
+	svc := service.New(log.NewNopLogger(), discard.NewCounter(), discard.NewCounter())
+	eps := endpoints.New(svc, endpoints.DefaultConfig(), log.NewNopLogger(), discard.NewHistogram(), trace)
+	mux := addhttp.NewHandler(context.Background(), eps, log.NewNopLogger(), trace)
+
+	// The caller's span should be the parent of ours.
+	parent := trace.StartSpan("caller")
+	req := httptest.NewRequest("POST", "/sum", strings.NewReader(`{"a":1,"b":2}`))
+	if err := trace.Inject(parent.Context(), opentracing.TextMap, opentracing.HTTPHeadersCarrier(req.Header)); err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if want, have := http.StatusOK, rec.Code; want != have {
+		t.Fatalf("want %d, have %d: %s", want, have, rec.Body.String())
+	}
+
+	spans := recorder.FinishedSpans()
+	if want, have := 1, len(spans); want != have {
+		t.Fatalf("want %d finished span, have %d", want, have)
+	}
+	span := spans[0]
+	if want, have := "Sum", span.OperationName; want != have {
+		t.Errorf("operation: want %q, have %q", want, have)
+	}
+	if want, have := parent.Context().(mocktracer.MockSpanContext).SpanID, span.ParentID; want != have {
+		t.Errorf("parent: want %d, have %d", want, have)
+	}
+	if want, have := "POST", span.Tag("http.method"); want != have {
+		t.Errorf("http.method: want %q, have %v", want, have)
+	}
+}
+
+func TestStringsvcTracingWiring(t *testing.T) {
+	trace := mocktracer.New()
+
+	// A stand-in for stringsvc, traced like the real thing.
+	var upstream endpoint.Endpoint
+	{
+		upstream = func(_ context.Context, request interface{}) (interface{}, error) {
+			return map[string]string{"v": strings.ToUpper(request.(map[string]string)["s"])}, nil
+		}
+		upstream = kitopentracing.TraceServer(trace, "Uppercase")(upstream)
+	}
+	upstreamServer := httptest.NewServer(httptransport.NewServer(
+		context.Background(),
+		upstream,
+		func(_ context.Context, r *http.Request) (interface{}, error) {
+			var req map[string]string
+			err := json.NewDecoder(r.Body).Decode(&req)
+			return req, err
+		},
+		func(_ context.Context, w http.ResponseWriter, response interface{}) error {
+			return json.NewEncoder(w).Encode(response)
+		},
+		httptransport.ServerBefore(kitopentracing.FromHTTPRequest(trace, "Uppercase", log.NewNopLogger())),
+	))
+	defer upstreamServer.Close()
+
+	uppercaser, err := stringsvc.New(upstreamServer.URL, log.NewNopLogger(), trace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := service.New(log.NewNopLogger(), discard.NewCounter(), discard.NewCounter(), service.UppercaseConcat(uppercaser))
+	eps := endpoints.New(svc, endpoints.DefaultConfig(), log.NewNopLogger(), discard.NewHistogram(), trace)
+	mux := addhttp.NewHandler(context.Background(), eps, log.NewNopLogger(), trace)
+
+	// The mock tracer can't start a trace from a request without one, so
+	// the caller starts it.
+	caller := trace.StartSpan("caller")
+	req := httptest.NewRequest("POST", "/concat", strings.NewReader(`{"a":"ab","b":"c"}`))
+	if err := trace.Inject(caller.Context(), opentracing.TextMap, opentracing.HTTPHeadersCarrier(req.Header)); err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if want, have := `{"v":"ABC"}`, strings.TrimSpace(rec.Body.String()); want != have {
+		t.Fatalf("want %s, have %s", want, have)
+	}
+
+	// Concat in addsvc, calling Uppercase in stringsvc, all in one trace.
+	spans := map[string]*mocktracer.MockSpan{}
+	for _, span := range trace.FinishedSpans() {
+		spans[fmt.Sprintf("%s %v", span.OperationName, span.Tag(string(ext.SpanKind)))] = span
+	}
+	var (
+		concat = spans["Concat server"]
+		client = spans["Uppercase client"]
+		server = spans["Uppercase server"]
+	)
+	if concat == nil || client == nil || server == nil || len(spans) != 3 {
+		t.Fatalf("want Concat server, Uppercase client, and Uppercase server spans, have %v", spans)
+	}
+	if want, have := caller.Context().(mocktracer.MockSpanContext).TraceID, server.SpanContext.TraceID; want != have {
+		t.Errorf("stringsvc trace: want %d, have %d", want, have)
+	}
+	if want, have := concat.SpanContext.SpanID, client.ParentID; want != have {
+		t.Errorf("client parent: want %d, have %d", want, have)
+	}
+	if want, have := client.SpanContext.SpanID, server.ParentID; want != have {
+		t.Errorf("stringsvc parent: want %d, have %d", want, have)
+	}
+}
+
+func TestStringsvcClientWiring(t *testing.T) {
+	var hits int64
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&hits, 1)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer slow.Close()
+
+	// Each call gives up at the deadline; once the breaker trips after more
+	// than 5 consecutive failures, calls fail without reaching stringsvc.
+	uppercaser, err := stringsvc.New(strings.TrimPrefix(slow.URL, "http://"), log.NewNopLogger(), opentracing.GlobalTracer(), stringsvc.Timeout(50*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 6; i++ {
+		begin := time.Now()
+		if _, err := uppercaser.Uppercase(context.Background(), "a"); err == nil {
+			t.Fatalf("call %d: want error, have none", i+1)
+		}
+		if took := time.Since(begin); took > 500*time.Millisecond {
+			t.Errorf("call %d: want timeout, took %v", i+1, took)
+		}
+	}
+	if _, err := uppercaser.Uppercase(context.Background(), "a"); err != gobreaker.ErrOpenState {
+		t.Errorf("after failures: want %v, have %v", gobreaker.ErrOpenState, err)
+	}
+	if max, have := int64(6), atomic.LoadInt64(&hits); have > max {
+		t.Errorf("hits: want at most %d, have %d", max, have)
+	}
+
+	// Only the start of an error response's body is kept in the error.
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, strings.Repeat("x", 1<<20), http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	uppercaser, err = stringsvc.New(failing.URL, log.NewNopLogger(), opentracing.GlobalTracer())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = uppercaser.Uppercase(context.Background(), "a")
+	if err == nil {
+		t.Fatal("want error, have none")
+	}
+	if n := len(err.Error()); n > 2<<10 {
+		t.Errorf("error: want at most %d bytes, have %d", 2<<10, n)
+	}
+}
@@ -0,0 +1,108 @@
+package client
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/tracing/opentracing"
+	httptransport "github.com/go-kit/kit/transport/http"
+	stdopentracing "github.com/opentracing/opentracing-go"
+
+	"github.com/peterbourgon/go-microservices/addsvc/pkg/endpoints"
+	addhttp "github.com/peterbourgon/go-microservices/addsvc/pkg/http"
+	"github.com/peterbourgon/go-microservices/addsvc/pkg/service"
+)
+
+// New returns a Service backed by an HTTP server living at the remote base
+// URL. If the URL has no scheme, http is assumed, so "host:port" is accepted.
+// Errors returned by the remote service are mapped back to the errors exported
+// by package service, so callers can compare them directly.
+func New(baseURL string, logger log.Logger, trace stdopentracing.Tracer) (service.Service, error) {
+	u, err := parseInstance(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	return endpoints.Endpoints{
+		SumEndpoint:     makeSumEndpoint(u, logger, trace),
+		SumBigEndpoint:  makeSumBigEndpoint(u, logger, trace),
+		SumManyEndpoint: makeSumManyEndpoint(u, logger, trace),
+		ConcatEndpoint:  makeConcatEndpoint(u, logger, trace),
+	}, nil
+}
+
+// parseInstance converts an instance string, which may be a full base URL or
+// just "host:port", to a URL.
+func parseInstance(instance string) (*url.URL, error) {
+	if !strings.Contains(instance, "://") {
+		instance = "http://" + instance
+	}
+	return url.Parse(instance)
+}
+
+func makeSumEndpoint(u *url.URL, logger log.Logger, trace stdopentracing.Tracer) endpoint.Endpoint {
+	var sumEndpoint endpoint.Endpoint
+	{
+		sumEndpoint = httptransport.NewClient(
+			"POST",
+			copyURL(u, "/sum"),
+			addhttp.EncodeGenericRequest,
+			addhttp.DecodeSumResponse,
+			httptransport.ClientBefore(opentracing.ToHTTPRequest(trace, logger)),
+		).Endpoint()
+		sumEndpoint = opentracing.TraceClient(trace, "Sum")(sumEndpoint)
+	}
+	return sumEndpoint
+}
+
+func makeSumBigEndpoint(u *url.URL, logger log.Logger, trace stdopentracing.Tracer) endpoint.Endpoint {
+	var sumBigEndpoint endpoint.Endpoint
+	{
+		sumBigEndpoint = httptransport.NewClient(
+			"POST",
+			copyURL(u, "/sum/big"),
+			addhttp.EncodeGenericRequest,
+			addhttp.DecodeSumBigResponse,
+			httptransport.ClientBefore(opentracing.ToHTTPRequest(trace, logger)),
+		).Endpoint()
+		sumBigEndpoint = opentracing.TraceClient(trace, "SumBig")(sumBigEndpoint)
+	}
+	return sumBigEndpoint
+}
+
+func makeSumManyEndpoint(u *url.URL, logger log.Logger, trace stdopentracing.Tracer) endpoint.Endpoint {
+	var sumManyEndpoint endpoint.Endpoint
+	{
+		sumManyEndpoint = httptransport.NewClient(
+			"POST",
+			copyURL(u, "/sum/many"),
+			addhttp.EncodeGenericRequest,
+			addhttp.DecodeSumManyResponse,
+			httptransport.ClientBefore(opentracing.ToHTTPRequest(trace, logger)),
+		).Endpoint()
+		sumManyEndpoint = opentracing.TraceClient(trace, "SumMany")(sumManyEndpoint)
+	}
+	return sumManyEndpoint
+}
+
+func makeConcatEndpoint(u *url.URL, logger log.Logger, trace stdopentracing.Tracer) endpoint.Endpoint {
+	var concatEndpoint endpoint.Endpoint
+	{
+		concatEndpoint = httptransport.NewClient(
+			"POST",
+			copyURL(u, "/concat"),
+			addhttp.EncodeGenericRequest,
+			addhttp.DecodeConcatResponse,
+			httptransport.ClientBefore(opentracing.ToHTTPRequest(trace, logger)),
+		).Endpoint()
+		concatEndpoint = opentracing.TraceClient(trace, "Concat")(concatEndpoint)
+	}
+	return concatEndpoint
+}
+
+func copyURL(base *url.URL, path string) *url.URL {
+	next := *base
+	next.Path = strings.TrimRight(base.Path, "/") + path
+	return &next
+}
@@ -0,0 +1,107 @@
+package client
+
+import (
+	"io"
+	"net/url"
+	"time"
+
+	"github.com/go-kit/kit/circuitbreaker"
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/ratelimit"
+	"github.com/go-kit/kit/sd"
+	"github.com/go-kit/kit/sd/lb"
+	rl "github.com/juju/ratelimit"
+	stdopentracing "github.com/opentracing/opentracing-go"
+	"github.com/sony/gobreaker"
+
+	"github.com/peterbourgon/go-microservices/addsvc/pkg/endpoints"
+	"github.com/peterbourgon/go-microservices/addsvc/pkg/service"
+)
+
+// Instancer converts a factory into a subscriber, which yields one endpoint
+// per known instance. It's the extension point for service discovery: wrap a
+// constructor from one of the sd subpackages, e.g.
+//
+//	func(f sd.Factory) (sd.Subscriber, error) {
+//	    return consul.NewSubscriber(client, f, logger, "addsvc", tags, true), nil
+//	}
+type Instancer func(sd.Factory) (sd.Subscriber, error)
+
+// Static returns an Instancer over a fixed set of instances. Each instance may
+// be a full base URL or just "host:port".
+func Static(instances ...string) Instancer {
+	return func(factory sd.Factory) (sd.Subscriber, error) {
+		var endpoints sd.FixedSubscriber
+		for _, instance := range instances {
+			e, _, err := factory(instance)
+			if err != nil {
+				return nil, err
+			}
+			endpoints = append(endpoints, e)
+		}
+		return endpoints, nil
+	}
+}
+
+// NewStatic returns a Service that load balances over a fixed set of
+// instances. See NewLoadBalanced for details.
+func NewStatic(instances []string, retryMax int, retryTimeout time.Duration, logger log.Logger, trace stdopentracing.Tracer) (service.Service, error) {
+	return NewLoadBalanced(Static(instances...), retryMax, retryTimeout, logger, trace)
+}
+
+// NewLoadBalanced returns a Service that distributes each request round-robin
+// over the instances yielded by the instancer. Each instance gets its own rate
+// limiter and circuit breaker, so one misbehaving instance can be taken out of
+// rotation without affecting the others. Failed requests are retried on other
+// instances up to retryMax times, or until retryTimeout elapses, whichever
+// comes first. Errors returned by the service itself are not retried.
+func NewLoadBalanced(instancer Instancer, retryMax int, retryTimeout time.Duration, logger log.Logger, trace stdopentracing.Tracer) (service.Service, error) {
+	balance := func(factory sd.Factory) (endpoint.Endpoint, error) {
+		subscriber, err := instancer(factory)
+		if err != nil {
+			return nil, err
+		}
+		return lb.Retry(retryMax, retryTimeout, lb.NewRoundRobin(subscriber)), nil
+	}
+
+	// The per-instance limits mirror the ones in endpoints.DefaultConfig.
+	var eps endpoints.Endpoints
+	var err error
+	if eps.SumEndpoint, err = balance(factoryFor(makeSumEndpoint, 1, 1, logger, trace)); err != nil {
+		return nil, err
+	}
+	if eps.SumBigEndpoint, err = balance(factoryFor(makeSumBigEndpoint, 1, 1, logger, trace)); err != nil {
+		return nil, err
+	}
+	if eps.SumManyEndpoint, err = balance(factoryFor(makeSumManyEndpoint, 1, 1, logger, trace)); err != nil {
+		return nil, err
+	}
+	if eps.ConcatEndpoint, err = balance(factoryFor(makeConcatEndpoint, 100, 100, logger, trace)); err != nil {
+		return nil, err
+	}
+	return eps, nil
+}
+
+// factoryFor returns a factory that constructs an endpoint for each instance,
+// with its own rate limiter and circuit breaker. The rate limiter throttles
+// rather than rejects, as a client would rather wait than fail.
+func factoryFor(
+	makeEndpoint func(*url.URL, log.Logger, stdopentracing.Tracer) endpoint.Endpoint,
+	rate float64, burst int64,
+	logger log.Logger, trace stdopentracing.Tracer,
+) sd.Factory {
+	return func(instance string) (endpoint.Endpoint, io.Closer, error) {
+		u, err := parseInstance(instance)
+		if err != nil {
+			return nil, nil, err
+		}
+		var e endpoint.Endpoint
+		{
+			e = makeEndpoint(u, logger, trace)
+			e = ratelimit.NewTokenBucketThrottler(rl.NewBucketWithRate(rate, burst), time.Sleep)(e)
+			e = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{}))(e)
+		}
+		return e, nil, nil
+	}
+}
@@ -0,0 +1,44 @@
+package endpoints
+
+import (
+	"fmt"
+
+	"github.com/peterbourgon/go-microservices/pkg/middleware"
+)
+
+// Config collects the tunable parameters of the endpoint middlewares wired in
+// by New, per method.
+type Config struct {
+	Sum    middleware.MethodConfig
+	Concat middleware.MethodConfig
+
+	// MaxCallers bounds the number of callers tracked by each method's
+	// per-caller rate limiter. It's only used if a CallerRate is set.
+	MaxCallers int
+}
+
+// DefaultConfig returns the Config used by the service unless told otherwise.
+// Sum admits one request per second on average, as it always has, but bursts
+// of up to 100, so that batches of up to 100 items, which are charged per item,
+// may be admitted at all.
+func DefaultConfig() Config {
+	return Config{
+		Sum:        middleware.MethodConfig{Rate: 1, Burst: 100},
+		Concat:     middleware.MethodConfig{Rate: 100, Burst: 100},
+		MaxCallers: 10000,
+	}
+}
+
+// Validate returns an error if any of the parameters are nonsensical.
+func (c Config) Validate() error {
+	if err := c.Sum.Validate(); err != nil {
+		return fmt.Errorf("Sum: %v", err)
+	}
+	if err := c.Concat.Validate(); err != nil {
+		return fmt.Errorf("Concat: %v", err)
+	}
+	if (c.Sum.CallerRate > 0 || c.Concat.CallerRate > 0) && c.MaxCallers < 1 {
+		return fmt.Errorf("max callers must be at least 1, have %d", c.MaxCallers)
+	}
+	return nil
+}
@@ -5,49 +5,175 @@ import (
 	"github.com/go-kit/kit/endpoint"
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/metrics"
-	"github.com/go-kit/kit/ratelimit"
 	"github.com/go-kit/kit/tracing/opentracing"
-	rl "github.com/juju/ratelimit"
 	stdopentracing "github.com/opentracing/opentracing-go"
 	"github.com/sony/gobreaker"
 	"golang.org/x/net/context"
 
 	"github.com/peterbourgon/go-microservices/addsvc/pkg/service"
+	"github.com/peterbourgon/go-microservices/pkg/middleware"
 )
 
 // New returns an Endpoints that wraps the provided server, and wires in all of
-// the expected endpoint middlewares via the various parameters.
-func New(svc service.Service, logger log.Logger, duration metrics.Histogram, trace stdopentracing.Tracer) Endpoints {
+// the expected endpoint middlewares via the various parameters. The config is
+// assumed to be valid; see Config.Validate.
+func New(svc service.Service, cfg Config, logger log.Logger, duration metrics.Histogram, trace stdopentracing.Tracer) Endpoints {
+	// The rate limiters and circuit breakers are shared between each method's
+	// endpoints, so that e.g. batching can't bypass them. SumBig and SumMany
+	// count as Sum. The rate limiters are applied outside of the circuit
+	// breakers, so that rejected requests don't count as failures, and one
+	// caller exceeding its rate limit can't trip the breaker for everyone.
+	var (
+		sumLimiter    = cfg.Sum.Limiter(cfg.MaxCallers)
+		sumBreaker    = cfg.Sum.Breaker("Sum")
+		concatLimiter = cfg.Concat.Limiter(cfg.MaxCallers)
+		concatBreaker = cfg.Concat.Breaker("Concat")
+	)
 	var sumEndpoint endpoint.Endpoint
 	{
 		sumEndpoint = MakeSumEndpoint(svc)
-		sumEndpoint = ratelimit.NewTokenBucketLimiter(rl.NewBucketWithRate(1, 1))(sumEndpoint)
-		sumEndpoint = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{}))(sumEndpoint)
+		sumEndpoint = circuitbreaker.Gobreaker(sumBreaker)(sumEndpoint)
+		sumEndpoint = sumLimiter.Middleware(middleware.Single)(sumEndpoint)
 		sumEndpoint = opentracing.TraceServer(trace, "Sum")(sumEndpoint)
-		sumEndpoint = LoggingMiddleware(log.NewContext(logger).With("method", "Sum"))(sumEndpoint)
-		sumEndpoint = InstrumentingMiddleware(duration.With("method", "Sum"))(sumEndpoint)
+		sumEndpoint = middleware.Logging(log.NewContext(logger).With("method", "Sum"))(sumEndpoint)
+		sumEndpoint = middleware.Instrumenting(duration.With("method", "Sum"))(sumEndpoint)
+	}
+	var sumBatchEndpoint endpoint.Endpoint
+	{
+		sumBatchEndpoint = MakeSumBatchEndpoint(svc)
+		sumBatchEndpoint = circuitbreaker.Gobreaker(sumBreaker)(sumBatchEndpoint)
+		sumBatchEndpoint = sumLimiter.Middleware(batchSize)(sumBatchEndpoint)
+		sumBatchEndpoint = opentracing.TraceServer(trace, "SumBatch")(sumBatchEndpoint)
+		sumBatchEndpoint = middleware.Logging(log.NewContext(logger).With("method", "SumBatch"))(sumBatchEndpoint)
+		sumBatchEndpoint = middleware.Instrumenting(duration.With("method", "SumBatch"))(sumBatchEndpoint)
+	}
+	var sumBigEndpoint endpoint.Endpoint
+	{
+		sumBigEndpoint = MakeSumBigEndpoint(svc)
+		sumBigEndpoint = circuitbreaker.Gobreaker(sumBreaker)(sumBigEndpoint)
+		sumBigEndpoint = sumLimiter.Middleware(middleware.Single)(sumBigEndpoint)
+		sumBigEndpoint = opentracing.TraceServer(trace, "SumBig")(sumBigEndpoint)
+		sumBigEndpoint = middleware.Logging(log.NewContext(logger).With("method", "SumBig"))(sumBigEndpoint)
+		sumBigEndpoint = middleware.Instrumenting(duration.With("method", "SumBig"))(sumBigEndpoint)
+	}
+	var sumManyEndpoint endpoint.Endpoint
+	{
+		sumManyEndpoint = MakeSumManyEndpoint(svc)
+		sumManyEndpoint = circuitbreaker.Gobreaker(sumBreaker)(sumManyEndpoint)
+		sumManyEndpoint = sumLimiter.Middleware(middleware.Single)(sumManyEndpoint)
+		sumManyEndpoint = opentracing.TraceServer(trace, "SumMany")(sumManyEndpoint)
+		sumManyEndpoint = middleware.Logging(log.NewContext(logger).With("method", "SumMany"))(sumManyEndpoint)
+		sumManyEndpoint = middleware.Instrumenting(duration.With("method", "SumMany"))(sumManyEndpoint)
 	}
 	var concatEndpoint endpoint.Endpoint
 	{
 		concatEndpoint = MakeConcatEndpoint(svc)
-		concatEndpoint = ratelimit.NewTokenBucketLimiter(rl.NewBucketWithRate(100, 100))(concatEndpoint)
-		concatEndpoint = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{}))(concatEndpoint)
+		concatEndpoint = circuitbreaker.Gobreaker(concatBreaker)(concatEndpoint)
+		concatEndpoint = concatLimiter.Middleware(middleware.Single)(concatEndpoint)
 		concatEndpoint = opentracing.TraceServer(trace, "Concat")(concatEndpoint)
-		concatEndpoint = LoggingMiddleware(log.NewContext(logger).With("method", "Concat"))(concatEndpoint)
-		concatEndpoint = InstrumentingMiddleware(duration.With("method", "Concat"))(concatEndpoint)
+		concatEndpoint = middleware.Logging(log.NewContext(logger).With("method", "Concat"))(concatEndpoint)
+		concatEndpoint = middleware.Instrumenting(duration.With("method", "Concat"))(concatEndpoint)
+	}
+	var concatBatchEndpoint endpoint.Endpoint
+	{
+		concatBatchEndpoint = MakeConcatBatchEndpoint(svc)
+		concatBatchEndpoint = circuitbreaker.Gobreaker(concatBreaker)(concatBatchEndpoint)
+		concatBatchEndpoint = concatLimiter.Middleware(batchSize)(concatBatchEndpoint)
+		concatBatchEndpoint = opentracing.TraceServer(trace, "ConcatBatch")(concatBatchEndpoint)
+		concatBatchEndpoint = middleware.Logging(log.NewContext(logger).With("method", "ConcatBatch"))(concatBatchEndpoint)
+		concatBatchEndpoint = middleware.Instrumenting(duration.With("method", "ConcatBatch"))(concatBatchEndpoint)
 	}
 	return Endpoints{
-		SumEndpoint:    sumEndpoint,
-		ConcatEndpoint: concatEndpoint,
+		SumEndpoint:         sumEndpoint,
+		SumBatchEndpoint:    sumBatchEndpoint,
+		SumBigEndpoint:      sumBigEndpoint,
+		SumManyEndpoint:     sumManyEndpoint,
+		ConcatEndpoint:      concatEndpoint,
+		ConcatBatchEndpoint: concatBatchEndpoint,
+		Breakers: map[string]*gobreaker.CircuitBreaker{
+			"Sum":    sumBreaker,
+			"Concat": concatBreaker,
+		},
+		Config: cfg,
 	}
 }
 
+// batchSize is the cost of a batch request: one token per item.
+func batchSize(request interface{}) int64 {
+	switch req := request.(type) {
+	case SumBatchRequest:
+		return int64(len(req))
+	case ConcatBatchRequest:
+		return int64(len(req))
+	}
+	return 1
+}
+
 // Endpoints collects all of the endpoints that compose an add service. It's
 // meant to be used as a helper struct, to collect all of the endpoints into a
 // single parameter.
 type Endpoints struct {
-	SumEndpoint    endpoint.Endpoint
-	ConcatEndpoint endpoint.Endpoint
+	SumEndpoint         endpoint.Endpoint
+	SumBatchEndpoint    endpoint.Endpoint
+	SumBigEndpoint      endpoint.Endpoint
+	SumManyEndpoint     endpoint.Endpoint
+	ConcatEndpoint      endpoint.Endpoint
+	ConcatBatchEndpoint endpoint.Endpoint
+
+	// Breakers are the circuit breakers wired in by New, by method name, so
+	// that their state may be reported e.g. in health checks. They're not set
+	// in clients.
+	Breakers map[string]*gobreaker.CircuitBreaker
+
+	// Config is the config that New wired the middlewares in with, so that
+	// transports can e.g. tell rejected clients when to retry. It's zero in
+	// clients.
+	Config Config
+}
+
+// Sum implements service.Service by invoking the SumEndpoint. Primarily useful
+// in a client, where the endpoints are backed by a remote instance.
+func (e Endpoints) Sum(ctx context.Context, a, b int) (int, error) {
+	response, err := e.SumEndpoint(ctx, SumRequest{A: a, B: b})
+	if err != nil {
+		return 0, err
+	}
+	resp := response.(SumResponse)
+	return resp.V, resp.Err
+}
+
+// SumBig implements service.Service by invoking the SumBigEndpoint. Primarily
+// useful in a client, where the endpoints are backed by a remote instance.
+func (e Endpoints) SumBig(ctx context.Context, a, b string) (string, error) {
+	response, err := e.SumBigEndpoint(ctx, SumBigRequest{A: a, B: b})
+	if err != nil {
+		return "", err
+	}
+	resp := response.(SumBigResponse)
+	return resp.V, resp.Err
+}
+
+// SumMany implements service.Service by invoking the SumManyEndpoint.
+// Primarily useful in a client, where the endpoints are backed by a remote
+// instance.
+func (e Endpoints) SumMany(ctx context.Context, operands []int) (int, error) {
+	response, err := e.SumManyEndpoint(ctx, SumManyRequest{Operands: operands})
+	if err != nil {
+		return 0, err
+	}
+	resp := response.(SumManyResponse)
+	return resp.V, resp.Err
+}
+
+// Concat implements service.Service by invoking the ConcatEndpoint. Primarily
+// useful in a client, where the endpoints are backed by a remote instance.
+func (e Endpoints) Concat(ctx context.Context, a, b string) (string, error) {
+	response, err := e.ConcatEndpoint(ctx, ConcatRequest{A: a, B: b})
+	if err != nil {
+		return "", err
+	}
+	resp := response.(ConcatResponse)
+	return resp.V, resp.Err
 }
 
 // MakeSumEndpoint constructs a Sum endpoint wrapping the service.
@@ -59,6 +185,39 @@ func MakeSumEndpoint(s service.Service) endpoint.Endpoint {
 	}
 }
 
+// MakeSumBatchEndpoint constructs a batch Sum endpoint wrapping the service.
+// Each item is summed in turn, and its result or error is returned in the
+// corresponding position of the response.
+func MakeSumBatchEndpoint(s service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(SumBatchRequest)
+		resp := make(SumBatchResponse, len(req))
+		for i, r := range req {
+			v, err := s.Sum(ctx, r.A, r.B)
+			resp[i] = SumResponse{V: v, Err: err}
+		}
+		return resp, nil
+	}
+}
+
+// MakeSumBigEndpoint constructs a SumBig endpoint wrapping the service.
+func MakeSumBigEndpoint(s service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(SumBigRequest)
+		v, err := s.SumBig(ctx, req.A, req.B)
+		return SumBigResponse{V: v, Err: err}, nil
+	}
+}
+
+// MakeSumManyEndpoint constructs a SumMany endpoint wrapping the service.
+func MakeSumManyEndpoint(s service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(SumManyRequest)
+		v, err := s.SumMany(ctx, req.Operands)
+		return SumManyResponse{V: v, Err: err}, nil
+	}
+}
+
 // MakeConcatEndpoint constructs a Concat endpoint wrapping the service.
 func MakeConcatEndpoint(s service.Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
@@ -68,6 +227,21 @@ func MakeConcatEndpoint(s service.Service) endpoint.Endpoint {
 	}
 }
 
+// MakeConcatBatchEndpoint constructs a batch Concat endpoint wrapping the
+// service. Each item is concatenated in turn, and its result or error is
+// returned in the corresponding position of the response.
+func MakeConcatBatchEndpoint(s service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(ConcatBatchRequest)
+		resp := make(ConcatBatchResponse, len(req))
+		for i, r := range req {
+			v, err := s.Concat(ctx, r.A, r.B)
+			resp[i] = ConcatResponse{V: v, Err: err}
+		}
+		return resp, nil
+	}
+}
+
 // Failer is an interface that should be implemented by response types.
 // Response encoders can check if responses are Failer, and if so if they've
 // failed, and if so encode them using a separate write path based on the error.
@@ -77,7 +251,8 @@ type Failer interface {
 
 // SumRequest collects the request parameters for the Sum method.
 type SumRequest struct {
-	A, B int
+	A int `json:"a"`
+	B int `json:"b"`
 }
 
 // SumResponse collects the response values for the Sum method.
@@ -89,9 +264,47 @@ type SumResponse struct {
 // Failed implements Failer.
 func (r SumResponse) Failed() error { return r.Err }
 
+// SumBatchRequest collects the request parameters for a batch of Sum calls.
+type SumBatchRequest []SumRequest
+
+// SumBatchResponse collects the response values for a batch of Sum calls, in
+// the same order as the requests.
+type SumBatchResponse []SumResponse
+
+// SumBigRequest collects the request parameters for the SumBig method. The
+// operands are decimal integers of arbitrary size.
+type SumBigRequest struct {
+	A string `json:"a"`
+	B string `json:"b"`
+}
+
+// SumBigResponse collects the response values for the SumBig method.
+type SumBigResponse struct {
+	V   string `json:"v"`
+	Err error  `json:"-"`
+}
+
+// Failed implements Failer.
+func (r SumBigResponse) Failed() error { return r.Err }
+
+// SumManyRequest collects the request parameters for the SumMany method.
+type SumManyRequest struct {
+	Operands []int `json:"operands"`
+}
+
+// SumManyResponse collects the response values for the SumMany method.
+type SumManyResponse struct {
+	V   int   `json:"v"`
+	Err error `json:"-"`
+}
+
+// Failed implements Failer.
+func (r SumManyResponse) Failed() error { return r.Err }
+
 // ConcatRequest collects the request parameters for the Concat method.
 type ConcatRequest struct {
-	A, B string
+	A string `json:"a"`
+	B string `json:"b"`
 }
 
 // ConcatResponse collects the response values for the Concat method.
@@ -102,3 +315,11 @@ type ConcatResponse struct {
 
 // Failed implements Failer.
 func (r ConcatResponse) Failed() error { return r.Err }
+
+// ConcatBatchRequest collects the request parameters for a batch of Concat
+// calls.
+type ConcatBatchRequest []ConcatRequest
+
+// ConcatBatchResponse collects the response values for a batch of Concat
+// calls, in the same order as the requests.
+type ConcatBatchResponse []ConcatResponse
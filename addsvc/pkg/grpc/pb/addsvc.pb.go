@@ -0,0 +1,452 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: addsvc.proto
+
+/*
+Package pb is a generated protocol buffer package.
+
+It is generated from these files:
+
+	addsvc.proto
+
+It has these top-level messages:
+
+	SumRequest
+	SumReply
+	ConcatRequest
+	ConcatReply
+	SumBigRequest
+	SumBigReply
+	SumManyRequest
+	SumManyReply
+	Error
+	FieldError
+*/
+package pb
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+
+import (
+	context "golang.org/x/net/context"
+	grpc "google.golang.org/grpc"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
+
+// The sum request contains two parameters.
+type SumRequest struct {
+	A int64 `protobuf:"varint,1,opt,name=a" json:"a,omitempty"`
+	B int64 `protobuf:"varint,2,opt,name=b" json:"b,omitempty"`
+}
+
+func (m *SumRequest) Reset()                    { *m = SumRequest{} }
+func (m *SumRequest) String() string            { return proto.CompactTextString(m) }
+func (*SumRequest) ProtoMessage()               {}
+func (*SumRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
+
+func (m *SumRequest) GetA() int64 {
+	if m != nil {
+		return m.A
+	}
+	return 0
+}
+
+func (m *SumRequest) GetB() int64 {
+	if m != nil {
+		return m.B
+	}
+	return 0
+}
+
+// The sum response contains the result of the calculation, or an error.
+type SumReply struct {
+	V   int64  `protobuf:"varint,1,opt,name=v" json:"v,omitempty"`
+	Err string `protobuf:"bytes,2,opt,name=err" json:"err,omitempty"`
+}
+
+func (m *SumReply) Reset()                    { *m = SumReply{} }
+func (m *SumReply) String() string            { return proto.CompactTextString(m) }
+func (*SumReply) ProtoMessage()               {}
+func (*SumReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+
+func (m *SumReply) GetV() int64 {
+	if m != nil {
+		return m.V
+	}
+	return 0
+}
+
+func (m *SumReply) GetErr() string {
+	if m != nil {
+		return m.Err
+	}
+	return ""
+}
+
+// The Concat request contains two parameters.
+type ConcatRequest struct {
+	A string `protobuf:"bytes,1,opt,name=a" json:"a,omitempty"`
+	B string `protobuf:"bytes,2,opt,name=b" json:"b,omitempty"`
+}
+
+func (m *ConcatRequest) Reset()                    { *m = ConcatRequest{} }
+func (m *ConcatRequest) String() string            { return proto.CompactTextString(m) }
+func (*ConcatRequest) ProtoMessage()               {}
+func (*ConcatRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+
+func (m *ConcatRequest) GetA() string {
+	if m != nil {
+		return m.A
+	}
+	return ""
+}
+
+func (m *ConcatRequest) GetB() string {
+	if m != nil {
+		return m.B
+	}
+	return ""
+}
+
+// The Concat response contains the result of the concatenation, or an error.
+type ConcatReply struct {
+	V   string `protobuf:"bytes,1,opt,name=v" json:"v,omitempty"`
+	Err string `protobuf:"bytes,2,opt,name=err" json:"err,omitempty"`
+}
+
+func (m *ConcatReply) Reset()                    { *m = ConcatReply{} }
+func (m *ConcatReply) String() string            { return proto.CompactTextString(m) }
+func (*ConcatReply) ProtoMessage()               {}
+func (*ConcatReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+
+func (m *ConcatReply) GetV() string {
+	if m != nil {
+		return m.V
+	}
+	return ""
+}
+
+func (m *ConcatReply) GetErr() string {
+	if m != nil {
+		return m.Err
+	}
+	return ""
+}
+
+// The SumBig request contains two decimal integers of arbitrary size.
+type SumBigRequest struct {
+	A string `protobuf:"bytes,1,opt,name=a" json:"a,omitempty"`
+	B string `protobuf:"bytes,2,opt,name=b" json:"b,omitempty"`
+}
+
+func (m *SumBigRequest) Reset()                    { *m = SumBigRequest{} }
+func (m *SumBigRequest) String() string            { return proto.CompactTextString(m) }
+func (*SumBigRequest) ProtoMessage()               {}
+func (*SumBigRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+
+func (m *SumBigRequest) GetA() string {
+	if m != nil {
+		return m.A
+	}
+	return ""
+}
+
+func (m *SumBigRequest) GetB() string {
+	if m != nil {
+		return m.B
+	}
+	return ""
+}
+
+// The SumBig response contains the decimal result of the calculation, or an
+// error.
+type SumBigReply struct {
+	V   string `protobuf:"bytes,1,opt,name=v" json:"v,omitempty"`
+	Err string `protobuf:"bytes,2,opt,name=err" json:"err,omitempty"`
+}
+
+func (m *SumBigReply) Reset()                    { *m = SumBigReply{} }
+func (m *SumBigReply) String() string            { return proto.CompactTextString(m) }
+func (*SumBigReply) ProtoMessage()               {}
+func (*SumBigReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+
+func (m *SumBigReply) GetV() string {
+	if m != nil {
+		return m.V
+	}
+	return ""
+}
+
+func (m *SumBigReply) GetErr() string {
+	if m != nil {
+		return m.Err
+	}
+	return ""
+}
+
+// The SumMany request contains any number of operands.
+type SumManyRequest struct {
+	Operands []int64 `protobuf:"varint,1,rep,packed,name=operands" json:"operands,omitempty"`
+}
+
+func (m *SumManyRequest) Reset()                    { *m = SumManyRequest{} }
+func (m *SumManyRequest) String() string            { return proto.CompactTextString(m) }
+func (*SumManyRequest) ProtoMessage()               {}
+func (*SumManyRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+
+func (m *SumManyRequest) GetOperands() []int64 {
+	if m != nil {
+		return m.Operands
+	}
+	return nil
+}
+
+// The SumMany response contains the result of the calculation, or an error.
+type SumManyReply struct {
+	V   int64  `protobuf:"varint,1,opt,name=v" json:"v,omitempty"`
+	Err string `protobuf:"bytes,2,opt,name=err" json:"err,omitempty"`
+}
+
+func (m *SumManyReply) Reset()                    { *m = SumManyReply{} }
+func (m *SumManyReply) String() string            { return proto.CompactTextString(m) }
+func (*SumManyReply) ProtoMessage()               {}
+func (*SumManyReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+
+func (m *SumManyReply) GetV() int64 {
+	if m != nil {
+		return m.V
+	}
+	return 0
+}
+
+func (m *SumManyReply) GetErr() string {
+	if m != nil {
+		return m.Err
+	}
+	return ""
+}
+
+// The error envelope of a failed HTTP request.
+type Error struct {
+	Error     string        `protobuf:"bytes,1,opt,name=error" json:"error,omitempty"`
+	Code      string        `protobuf:"bytes,2,opt,name=code" json:"code,omitempty"`
+	RequestId string        `protobuf:"bytes,3,opt,name=request_id,json=requestId" json:"request_id,omitempty"`
+	Fields    []*FieldError `protobuf:"bytes,4,rep,name=fields" json:"fields,omitempty"`
+}
+
+func (m *Error) Reset()                    { *m = Error{} }
+func (m *Error) String() string            { return proto.CompactTextString(m) }
+func (*Error) ProtoMessage()               {}
+func (*Error) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+
+func (m *Error) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+func (m *Error) GetCode() string {
+	if m != nil {
+		return m.Code
+	}
+	return ""
+}
+
+func (m *Error) GetRequestId() string {
+	if m != nil {
+		return m.RequestId
+	}
+	return ""
+}
+
+func (m *Error) GetFields() []*FieldError {
+	if m != nil {
+		return m.Fields
+	}
+	return nil
+}
+
+// A field of an invalid HTTP request, and what's wrong with it.
+type FieldError struct {
+	Field string `protobuf:"bytes,1,opt,name=field" json:"field,omitempty"`
+	Error string `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *FieldError) Reset()                    { *m = FieldError{} }
+func (m *FieldError) String() string            { return proto.CompactTextString(m) }
+func (*FieldError) ProtoMessage()               {}
+func (*FieldError) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+
+func (m *FieldError) GetField() string {
+	if m != nil {
+		return m.Field
+	}
+	return ""
+}
+
+func (m *FieldError) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+func init() {
+	proto.RegisterType((*SumRequest)(nil), "pb.SumRequest")
+	proto.RegisterType((*SumReply)(nil), "pb.SumReply")
+	proto.RegisterType((*ConcatRequest)(nil), "pb.ConcatRequest")
+	proto.RegisterType((*ConcatReply)(nil), "pb.ConcatReply")
+	proto.RegisterType((*SumBigRequest)(nil), "pb.SumBigRequest")
+	proto.RegisterType((*SumBigReply)(nil), "pb.SumBigReply")
+	proto.RegisterType((*SumManyRequest)(nil), "pb.SumManyRequest")
+	proto.RegisterType((*SumManyReply)(nil), "pb.SumManyReply")
+	proto.RegisterType((*Error)(nil), "pb.Error")
+	proto.RegisterType((*FieldError)(nil), "pb.FieldError")
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// Client API for Add service
+
+type AddClient interface {
+	// Sums two integers.
+	Sum(ctx context.Context, in *SumRequest, opts ...grpc.CallOption) (*SumReply, error)
+	// Concatenates two strings.
+	Concat(ctx context.Context, in *ConcatRequest, opts ...grpc.CallOption) (*ConcatReply, error)
+}
+
+type addClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewAddClient(cc *grpc.ClientConn) AddClient {
+	return &addClient{cc}
+}
+
+func (c *addClient) Sum(ctx context.Context, in *SumRequest, opts ...grpc.CallOption) (*SumReply, error) {
+	out := new(SumReply)
+	err := grpc.Invoke(ctx, "/pb.Add/Sum", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *addClient) Concat(ctx context.Context, in *ConcatRequest, opts ...grpc.CallOption) (*ConcatReply, error) {
+	out := new(ConcatReply)
+	err := grpc.Invoke(ctx, "/pb.Add/Concat", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Server API for Add service
+
+type AddServer interface {
+	// Sums two integers.
+	Sum(context.Context, *SumRequest) (*SumReply, error)
+	// Concatenates two strings.
+	Concat(context.Context, *ConcatRequest) (*ConcatReply, error)
+}
+
+func RegisterAddServer(s *grpc.Server, srv AddServer) {
+	s.RegisterService(&_Add_serviceDesc, srv)
+}
+
+func _Add_Sum_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SumRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AddServer).Sum(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.Add/Sum",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AddServer).Sum(ctx, req.(*SumRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Add_Concat_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ConcatRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AddServer).Concat(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.Add/Concat",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AddServer).Concat(ctx, req.(*ConcatRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Add_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "pb.Add",
+	HandlerType: (*AddServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Sum",
+			Handler:    _Add_Sum_Handler,
+		},
+		{
+			MethodName: "Concat",
+			Handler:    _Add_Concat_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "addsvc.proto",
+}
+
+func init() { proto.RegisterFile("addsvc.proto", fileDescriptor0) }
+
+var fileDescriptor0 = []byte{
+	// 321 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x92, 0xcd, 0x4b, 0xf3, 0x40,
+	0x10, 0xc6, 0xdf, 0x74, 0xdb, 0xd2, 0x4c, 0xf3, 0x56, 0x1d, 0x3c, 0x84, 0x82, 0x50, 0x16, 0x94,
+	0xe0, 0x47, 0x0e, 0xf5, 0xe2, 0x55, 0x45, 0xc1, 0x83, 0x97, 0xe4, 0x2a, 0xc8, 0xa6, 0xbb, 0x4a,
+	0xa1, 0xe9, 0xae, 0x9b, 0xa6, 0x98, 0xff, 0x5e, 0x76, 0xf3, 0xd5, 0x20, 0x42, 0x6e, 0xf3, 0x3c,
+	0xf3, 0xe4, 0x37, 0x43, 0x76, 0xc0, 0x63, 0x9c, 0x67, 0xfb, 0x55, 0xa8, 0xb4, 0xdc, 0x49, 0x1c,
+	0xa8, 0x84, 0x06, 0x00, 0x71, 0x9e, 0x46, 0xe2, 0x2b, 0x17, 0xd9, 0x0e, 0x3d, 0x70, 0x98, 0xef,
+	0x2c, 0x9c, 0x80, 0x44, 0x0e, 0x33, 0x2a, 0xf1, 0x07, 0xa5, 0x4a, 0xe8, 0x25, 0x4c, 0x6c, 0x52,
+	0x6d, 0x0a, 0xd3, 0xd9, 0xd7, 0xb9, 0x3d, 0x1e, 0x03, 0x11, 0x5a, 0xdb, 0xa4, 0x1b, 0x99, 0x92,
+	0x5e, 0xc1, 0xff, 0x47, 0xb9, 0x5d, 0xb1, 0xdd, 0x2f, 0xb0, 0xdb, 0x01, 0xbb, 0x06, 0x7c, 0x03,
+	0xd3, 0x3a, 0xdc, 0x61, 0xbb, 0x7f, 0xb2, 0xe3, 0x3c, 0x7d, 0x58, 0x7f, 0xf6, 0x64, 0xd7, 0xe1,
+	0x3e, 0xec, 0x6b, 0x98, 0xc5, 0x79, 0xfa, 0xca, 0xb6, 0x45, 0x0d, 0x9f, 0xc3, 0x44, 0x2a, 0xa1,
+	0xd9, 0x96, 0x67, 0xbe, 0xb3, 0x20, 0x01, 0x89, 0x1a, 0x4d, 0x43, 0xf0, 0x9a, 0x74, 0x9f, 0xbf,
+	0xf2, 0x0d, 0xa3, 0x27, 0xad, 0xa5, 0xc6, 0x53, 0x18, 0x09, 0x53, 0x54, 0xab, 0x94, 0x02, 0x11,
+	0x86, 0x2b, 0xc9, 0x45, 0xf5, 0x85, 0xad, 0xf1, 0x0c, 0x40, 0x97, 0x9b, 0xbc, 0xaf, 0xb9, 0x4f,
+	0x6c, 0xc7, 0xad, 0x9c, 0x17, 0x8e, 0x17, 0x30, 0xfe, 0x58, 0x8b, 0x0d, 0xcf, 0xfc, 0xe1, 0x82,
+	0x04, 0xd3, 0xe5, 0x2c, 0x54, 0x49, 0xf8, 0x6c, 0x1c, 0x3b, 0x28, 0xaa, 0xba, 0xf4, 0x0e, 0xa0,
+	0x75, 0xcd, 0x78, 0xeb, 0xd7, 0xe3, 0xad, 0x68, 0x97, 0x1a, 0x1c, 0x2c, 0xb5, 0x7c, 0x03, 0x72,
+	0xcf, 0x39, 0x9e, 0x03, 0x89, 0xf3, 0x14, 0x2d, 0xbf, 0xbd, 0x97, 0xb9, 0xd7, 0x68, 0xb5, 0x29,
+	0xe8, 0x3f, 0x0c, 0x61, 0x5c, 0x3e, 0x25, 0x9e, 0x98, 0x4e, 0xe7, 0x06, 0xe6, 0x47, 0x87, 0x96,
+	0xcd, 0x27, 0x63, 0x7b, 0x88, 0xb7, 0x3f, 0x03, 0x00, 0xe6, 0x64, 0x85, 0xf0, 0x98, 0x02, 0x00,
+	0x00,
+}
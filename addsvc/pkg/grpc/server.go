@@ -0,0 +1,112 @@
+package grpc
+
+import (
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/tracing/opentracing"
+	grpctransport "github.com/go-kit/kit/transport/grpc"
+	stdopentracing "github.com/opentracing/opentracing-go"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/peterbourgon/go-microservices/addsvc/pkg/endpoints"
+	"github.com/peterbourgon/go-microservices/addsvc/pkg/grpc/pb"
+	"github.com/peterbourgon/go-microservices/pkg/middleware"
+)
+
+// NewServer returns a gRPC AddServer that makes a set of endpoints available
+// as the methods of the Add service defined in package pb.
+func NewServer(ctx context.Context, endpoints endpoints.Endpoints, logger log.Logger, trace stdopentracing.Tracer) pb.AddServer {
+	options := []grpctransport.ServerOption{
+		grpctransport.ServerErrorLogger(logger),
+	}
+	return &grpcServer{
+		sum: grpctransport.NewServer(
+			ctx,
+			endpoints.SumEndpoint,
+			DecodeSumRequest,
+			EncodeSumResponse,
+			append(options, grpctransport.ServerBefore(opentracing.FromGRPCRequest(trace, "Sum", logger), populateCaller))...,
+		),
+		concat: grpctransport.NewServer(
+			ctx,
+			endpoints.ConcatEndpoint,
+			DecodeConcatRequest,
+			EncodeConcatResponse,
+			append(options, grpctransport.ServerBefore(opentracing.FromGRPCRequest(trace, "Concat", logger), populateCaller))...,
+		),
+	}
+}
+
+// apiKeyMetadata identifies the caller for per-caller rate limiting. Requests
+// without it share a single rate limit.
+const apiKeyMetadata = "x-api-key"
+
+// populateCaller is a transport/grpc.RequestFunc that sets the caller key used
+// by the per-caller rate limiter in package middleware.
+func populateCaller(ctx context.Context, md *metadata.MD) context.Context {
+	if keys := (*md)[apiKeyMetadata]; len(keys) > 0 && keys[0] != "" {
+		return middleware.WithCaller(ctx, "key:"+keys[0])
+	}
+	return ctx
+}
+
+type grpcServer struct {
+	sum    grpctransport.Handler
+	concat grpctransport.Handler
+}
+
+func (s *grpcServer) Sum(ctx context.Context, req *pb.SumRequest) (*pb.SumReply, error) {
+	_, rep, err := s.sum.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return rep.(*pb.SumReply), nil
+}
+
+func (s *grpcServer) Concat(ctx context.Context, req *pb.ConcatRequest) (*pb.ConcatReply, error) {
+	_, rep, err := s.concat.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return rep.(*pb.ConcatReply), nil
+}
+
+// DecodeSumRequest is a transport/grpc.DecodeRequestFunc that converts a
+// gRPC sum request to a user-domain sum request. Primarily useful in a server.
+func DecodeSumRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.SumRequest)
+	return endpoints.SumRequest{A: int(req.A), B: int(req.B)}, nil
+}
+
+// DecodeConcatRequest is a transport/grpc.DecodeRequestFunc that converts a
+// gRPC concat request to a user-domain concat request. Primarily useful in a
+// server.
+func DecodeConcatRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.ConcatRequest)
+	return endpoints.ConcatRequest{A: req.A, B: req.B}, nil
+}
+
+// EncodeSumResponse is a transport/grpc.EncodeResponseFunc that converts a
+// user-domain sum response to a gRPC sum reply. Business errors are carried in
+// the reply's err field, rather than as a gRPC error. Primarily useful in a
+// server.
+func EncodeSumResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(endpoints.SumResponse)
+	return &pb.SumReply{V: int64(resp.V), Err: err2str(resp.Err)}, nil
+}
+
+// EncodeConcatResponse is a transport/grpc.EncodeResponseFunc that converts a
+// user-domain concat response to a gRPC concat reply. Business errors are
+// carried in the reply's err field, rather than as a gRPC error. Primarily
+// useful in a server.
+func EncodeConcatResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(endpoints.ConcatResponse)
+	return &pb.ConcatReply{V: resp.V, Err: err2str(resp.Err)}, nil
+}
+
+func err2str(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
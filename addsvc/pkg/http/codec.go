@@ -0,0 +1,306 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/golang/protobuf/proto"
+	"github.com/ugorji/go/codec"
+	"golang.org/x/net/context"
+
+	"github.com/peterbourgon/go-microservices/addsvc/pkg/endpoints"
+	"github.com/peterbourgon/go-microservices/addsvc/pkg/grpc/pb"
+)
+
+// ErrUnsupportedMediaType is returned when the request body has a content type
+// the route doesn't accept.
+var ErrUnsupportedMediaType = errors.New("unsupported media type")
+
+// ErrNotAcceptable is returned when none of the media types in the request's
+// Accept header can be produced by the route.
+var ErrNotAcceptable = errors.New("no acceptable media type")
+
+// A bodyCodec encodes and decodes request and response bodies of one media
+// type.
+type bodyCodec struct {
+	mediaType   string
+	contentType string // including parameters, for the response header
+	decode      func(r io.Reader, v interface{}) error
+	encode      func(w io.Writer, v interface{}) error
+
+	// presence is set if absent fields can be told apart from zero values,
+	// by decoding into maps, so required fields can be enforced.
+	presence bool
+}
+
+var (
+	jsonCodec = &bodyCodec{
+		mediaType:   "application/json",
+		contentType: "application/json; charset=utf-8",
+		decode:      decodeJSON,
+		encode:      func(w io.Writer, v interface{}) error { return json.NewEncoder(w).Encode(v) },
+		presence:    true,
+	}
+	msgpackCodec = &bodyCodec{
+		mediaType:   "application/msgpack",
+		contentType: "application/msgpack",
+		decode:      func(r io.Reader, v interface{}) error { return codec.NewDecoder(r, msgpackHandle).Decode(v) },
+		encode:      func(w io.Writer, v interface{}) error { return codec.NewEncoder(w, msgpackHandle).Encode(v) },
+		presence:    true,
+	}
+	protobufCodec = &bodyCodec{
+		mediaType:   "application/x-protobuf",
+		contentType: "application/x-protobuf",
+		decode:      decodeProtobuf,
+		encode:      encodeProtobuf,
+	}
+)
+
+// msgpackHandle encodes and decodes msgpack bodies. The codec package honors
+// json struct tags, so msgpack bodies have the same field names as JSON bodies.
+// Like JSON bodies, they may not have unknown fields.
+var msgpackHandle = func() *codec.MsgpackHandle {
+	var h codec.MsgpackHandle
+	h.WriteExt = true // use the str and bin types of the current spec
+	h.ErrorIfNoField = true
+	h.MapType = reflect.TypeOf(map[string]interface{}(nil))
+	return &h
+}()
+
+// allCodecs are offered by routes whose messages all have protobuf
+// equivalents; batchCodecs are offered by routes whose messages don't. The
+// first codec is the default.
+var (
+	allCodecs   = []*bodyCodec{jsonCodec, msgpackCodec, protobufCodec}
+	batchCodecs = []*bodyCodec{jsonCodec, msgpackCodec}
+)
+
+type contextKey int
+
+const (
+	negotiationKey contextKey = iota
+	methodConfigKey
+)
+
+// negotiation is the outcome of content negotiation for a single request.
+// Either codec is nil if no offered codec matched.
+type negotiation struct {
+	request  *bodyCodec
+	response *bodyCodec
+}
+
+// negotiate returns a transport/http.RequestFunc that chooses the codecs for
+// the request and response bodies from those offered, according to the
+// request's Content-Type and Accept headers. Missing headers select the first
+// codec offered. Decoders fail with ErrUnsupportedMediaType or ErrNotAcceptable
+// if there's no match.
+func negotiate(offered ...*bodyCodec) httptransport.RequestFunc {
+	return func(ctx context.Context, r *http.Request) context.Context {
+		return context.WithValue(ctx, negotiationKey, negotiation{
+			request:  matchContentType(offered, r.Header.Get("Content-Type")),
+			response: matchAccept(offered, r.Header.Get("Accept")),
+		})
+	}
+}
+
+func matchContentType(offered []*bodyCodec, contentType string) *bodyCodec {
+	if contentType == "" {
+		return offered[0]
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil
+	}
+	for _, c := range offered {
+		if c.mediaType == mediaType {
+			return c
+		}
+	}
+	return nil
+}
+
+// matchAccept returns the offered codec with the highest quality in the
+// Accept header, preferring earlier codecs among equals. Each codec's quality
+// is that of the most specific media range that matches it, per RFC 7231
+// section 5.3.2, so e.g. "*/*, application/json;q=0" rules out JSON.
+func matchAccept(offered []*bodyCodec, accept string) *bodyCodec {
+	if accept == "" {
+		return offered[0]
+	}
+	quality := map[string]float64{} // by media range
+	for _, s := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(s))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		quality[mediaType] = q
+	}
+	var (
+		best  *bodyCodec
+		bestQ float64
+	)
+	for _, c := range offered {
+		q, ok := quality[c.mediaType]
+		if !ok {
+			q, ok = quality[strings.SplitN(c.mediaType, "/", 2)[0]+"/*"]
+		}
+		if !ok {
+			q = quality["*/*"]
+		}
+		if q > bestQ {
+			best, bestQ = c, q
+		}
+	}
+	return best
+}
+
+// requestCodec returns the codec chosen by negotiate for the request body. If
+// negotiate wasn't used, e.g. because a decoder is used outside of NewHandler,
+// it's JSON.
+func requestCodec(ctx context.Context) (*bodyCodec, error) {
+	n, ok := ctx.Value(negotiationKey).(negotiation)
+	if !ok {
+		return jsonCodec, nil
+	}
+	if n.request == nil {
+		return nil, ErrUnsupportedMediaType
+	}
+	if err := acceptable(ctx); err != nil {
+		return nil, err
+	}
+	return n.request, nil
+}
+
+// acceptable returns ErrNotAcceptable if negotiate found no codec for the
+// response body.
+func acceptable(ctx context.Context) error {
+	if n, ok := ctx.Value(negotiationKey).(negotiation); ok && n.response == nil {
+		return ErrNotAcceptable
+	}
+	return nil
+}
+
+// responseCodec returns the codec chosen by negotiate for the response body,
+// or JSON if there wasn't one.
+func responseCodec(ctx context.Context) *bodyCodec {
+	if n, ok := ctx.Value(negotiationKey).(negotiation); ok && n.response != nil {
+		return n.response
+	}
+	return jsonCodec
+}
+
+// decodeRequest decodes the request body into v with the negotiated codec. If
+// the codec supports it, fields that aren't optional are required; see
+// missingFields.
+func decodeRequest(ctx context.Context, r *http.Request, v interface{}) error {
+	c, err := requestCodec(ctx)
+	if err != nil {
+		return err
+	}
+	if !c.presence {
+		return bodyError(c.decode(r.Body, v))
+	}
+	buf, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return bodyError(err)
+	}
+	if err := c.decode(bytes.NewReader(buf), v); err != nil {
+		return err
+	}
+	var generic interface{}
+	if err := c.decode(bytes.NewReader(buf), &generic); err != nil {
+		return err
+	}
+	if fields := missingFields(v, generic, ""); len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+// encodeResponse encodes v to the response body with the negotiated codec.
+func encodeResponse(ctx context.Context, w http.ResponseWriter, v interface{}) error {
+	c := responseCodec(ctx)
+	w.Header().Set("Content-Type", c.contentType)
+	return c.encode(w, v)
+}
+
+// decodeProtobuf decodes a protobuf message from package pb into the
+// corresponding request type from package endpoints.
+func decodeProtobuf(r io.Reader, v interface{}) error {
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	switch req := v.(type) {
+	case *endpoints.SumRequest:
+		var m pb.SumRequest
+		err = proto.Unmarshal(buf, &m)
+		*req = endpoints.SumRequest{A: int(m.A), B: int(m.B)}
+	case *endpoints.SumBigRequest:
+		var m pb.SumBigRequest
+		err = proto.Unmarshal(buf, &m)
+		*req = endpoints.SumBigRequest{A: m.A, B: m.B}
+	case *endpoints.SumManyRequest:
+		var m pb.SumManyRequest
+		err = proto.Unmarshal(buf, &m)
+		operands := make([]int, len(m.Operands))
+		for i, x := range m.Operands {
+			operands[i] = int(x)
+		}
+		*req = endpoints.SumManyRequest{Operands: operands}
+	case *endpoints.ConcatRequest:
+		var m pb.ConcatRequest
+		err = proto.Unmarshal(buf, &m)
+		*req = endpoints.ConcatRequest{A: m.A, B: m.B}
+	default:
+		return fmt.Errorf("can't decode %T from protobuf", v)
+	}
+	return err
+}
+
+// encodeProtobuf encodes a response type from package endpoints, or an error
+// envelope, as the corresponding protobuf message from package pb. Errors
+// are always carried in the envelope, never in the reply's err field.
+func encodeProtobuf(w io.Writer, v interface{}) error {
+	var m proto.Message
+	switch resp := v.(type) {
+	case endpoints.SumResponse:
+		m = &pb.SumReply{V: int64(resp.V)}
+	case endpoints.SumBigResponse:
+		m = &pb.SumBigReply{V: resp.V}
+	case endpoints.SumManyResponse:
+		m = &pb.SumManyReply{V: int64(resp.V)}
+	case endpoints.ConcatResponse:
+		m = &pb.ConcatReply{V: resp.V}
+	case errorEnvelope:
+		e := &pb.Error{Error: resp.Error, Code: resp.Code, RequestId: resp.RequestID}
+		for _, f := range resp.Fields {
+			e.Fields = append(e.Fields, &pb.FieldError{Field: f.Field, Error: f.Error})
+		}
+		m = e
+	default:
+		return fmt.Errorf("can't encode %T as protobuf", v)
+	}
+	buf, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(buf)
+	return err
+}
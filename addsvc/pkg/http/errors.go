@@ -0,0 +1,266 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-kit/kit/ratelimit"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/sony/gobreaker"
+	"golang.org/x/net/context"
+
+	"github.com/peterbourgon/go-microservices/addsvc/pkg/service"
+	"github.com/peterbourgon/go-microservices/pkg/httpcontext"
+	"github.com/peterbourgon/go-microservices/pkg/middleware"
+)
+
+// Error codes are the stable, machine-readable identifiers of errors in the
+// error envelope. Unlike messages, they may be relied upon by clients.
+const (
+	codeTwoZeroes         = "two_zeroes"
+	codeIntOverflow       = "int_overflow"
+	codeInvalidNumber     = "invalid_number"
+	codeMaxSizeExceeded   = "max_size_exceeded"
+	codeRateLimited       = "rate_limited"
+	codeCallerRateLimited = "caller_rate_limited"
+	codeCircuitOpen       = "circuit_open"
+	codeCircuitHalfOpen   = "circuit_half_open"
+	codeBatchTooLarge     = "batch_too_large"
+	codeDecodeError       = "decode_error"
+	codeUnsupportedMedia  = "unsupported_media_type"
+	codeNotAcceptable     = "not_acceptable"
+	codeMethodNotAllowed  = "method_not_allowed"
+	codeInvalidRequest    = "invalid_request"
+	codeBodyTooLarge      = "body_too_large"
+	codeInternalError     = "internal_error"
+)
+
+// errorCodes maps the errors that clients may want to compare against to
+// their codes. It's consulted in both directions.
+var errorCodes = map[error]string{
+	service.ErrTwoZeroes:         codeTwoZeroes,
+	service.ErrIntOverflow:       codeIntOverflow,
+	service.ErrInvalidNumber:     codeInvalidNumber,
+	service.ErrMaxSizeExceeded:   codeMaxSizeExceeded,
+	ratelimit.ErrLimited:         codeRateLimited,
+	middleware.ErrRateLimited:    codeCallerRateLimited,
+	gobreaker.ErrOpenState:       codeCircuitOpen,
+	gobreaker.ErrTooManyRequests: codeCircuitHalfOpen,
+	middleware.ErrBatchTooLarge:  codeBatchTooLarge,
+	ErrUnsupportedMediaType:      codeUnsupportedMedia,
+	ErrNotAcceptable:             codeNotAcceptable,
+	ErrMethodNotAllowed:          codeMethodNotAllowed,
+	ErrInvalidRequest:            codeInvalidRequest,
+	ErrBodyTooLarge:              codeBodyTooLarge,
+}
+
+// ErrMethodNotAllowed is returned when the route doesn't support the request
+// method. Decoders return a MethodNotAllowedError, which describes it in
+// detail.
+var ErrMethodNotAllowed = errors.New("method not allowed")
+
+// MethodNotAllowedError is returned by decoders when the route doesn't support
+// the request method. The allowed methods are returned in the Allow header.
+type MethodNotAllowedError struct {
+	Allowed []string
+}
+
+func (e *MethodNotAllowedError) Error() string {
+	return fmt.Sprintf("%v; allowed: %s", ErrMethodNotAllowed, strings.Join(e.Allowed, ", "))
+}
+
+// ErrInvalidRequest is returned when fields of the request are missing,
+// unknown, or of the wrong type. Decoders return a ValidationError, which
+// describes each field in detail.
+var ErrInvalidRequest = errors.New("invalid request")
+
+// ValidationError is returned by decoders when fields of the request are
+// invalid. The fields are returned in the error envelope.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	s := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		s[i] = f.Field + ": " + f.Error
+	}
+	return fmt.Sprintf("%v: %s", ErrInvalidRequest, strings.Join(s, "; "))
+}
+
+// FieldError describes what's wrong with a single field of a request. Fields
+// of batch items are prefixed with their index, e.g. "[1].a".
+type FieldError struct {
+	Field string `json:"field"`
+	Error string `json:"error"`
+}
+
+// invalid returns a ValidationError for a single field.
+func invalid(field, format string, args ...interface{}) error {
+	return &ValidationError{Fields: []FieldError{{Field: field, Error: fmt.Sprintf(format, args...)}}}
+}
+
+// ErrBodyTooLarge is returned when the request body exceeds the maximum size
+// set with MaxBodySize.
+var ErrBodyTooLarge = errors.New("request body too large")
+
+// errorEnvelope is the body of every error response.
+type errorEnvelope struct {
+	Error     string       `json:"error"` // human-readable message
+	Code      string       `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Fields    []FieldError `json:"fields,omitempty"` // of a ValidationError
+}
+
+// withMethodConfig returns a transport/http.RequestFunc that sets the config of
+// the route's method in the context, from which errorEncoder derives the
+// Retry-After hints of rejected requests.
+func withMethodConfig(c middleware.MethodConfig) httptransport.RequestFunc {
+	return func(ctx context.Context, r *http.Request) context.Context {
+		return context.WithValue(ctx, methodConfigKey, c)
+	}
+}
+
+// retryAfter returns the Retry-After hint for a request rejected with err, in
+// whole seconds, rounded up. It's at least 1, even if there's no method config
+// in the context.
+func retryAfter(ctx context.Context, err error) string {
+	c, _ := ctx.Value(methodConfigKey).(middleware.MethodConfig)
+	seconds := int(math.Ceil(c.RetryAfter(cause(err)).Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	return strconv.Itoa(seconds)
+}
+
+func errorEncoder(ctx context.Context, err error, w http.ResponseWriter) {
+	code := err2code(err)
+	switch code {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		w.Header().Set("Retry-After", retryAfter(ctx, err))
+	}
+	if e, ok := cause(err).(*MethodNotAllowedError); ok {
+		w.Header().Set("Allow", strings.Join(e.Allowed, ", "))
+	}
+	httpcontext.SetRequestIDHeader(ctx, w)
+	envelope := errorEnvelope{
+		Error:     err.Error(),
+		Code:      err2errcode(err),
+		RequestID: middleware.RequestIDFromContext(ctx),
+	}
+	if e, ok := cause(err).(*ValidationError); ok {
+		envelope.Fields = e.Fields
+	}
+	c := responseCodec(ctx)
+	w.Header().Set("Content-Type", c.contentType)
+	w.WriteHeader(code)
+	c.encode(w, envelope)
+}
+
+// sentinel returns the exported error value corresponding to err, if err is
+// a more detailed error type describing the same condition. Otherwise, it
+// returns err.
+func sentinel(err error) error {
+	switch err.(type) {
+	case service.MaxSizeError:
+		return service.ErrMaxSizeExceeded
+	case *MethodNotAllowedError:
+		return ErrMethodNotAllowed
+	case *ValidationError:
+		return ErrInvalidRequest
+	}
+	return err
+}
+
+// cause returns the error wrapped by a transport error, or err itself.
+func cause(err error) error {
+	if e, ok := err.(httptransport.Error); ok {
+		return e.Err
+	}
+	return err
+}
+
+func err2code(err error) int {
+	switch sentinel(err) {
+	case service.ErrTwoZeroes, service.ErrMaxSizeExceeded, service.ErrIntOverflow, service.ErrInvalidNumber:
+		return http.StatusBadRequest
+	case middleware.ErrRateLimited, ratelimit.ErrLimited:
+		return http.StatusTooManyRequests
+	case gobreaker.ErrOpenState, gobreaker.ErrTooManyRequests:
+		return http.StatusServiceUnavailable
+	case middleware.ErrBatchTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case ErrUnsupportedMediaType:
+		return http.StatusUnsupportedMediaType
+	case ErrNotAcceptable:
+		return http.StatusNotAcceptable
+	case ErrMethodNotAllowed:
+		return http.StatusMethodNotAllowed
+	case ErrInvalidRequest:
+		return http.StatusBadRequest
+	case ErrBodyTooLarge:
+		return http.StatusRequestEntityTooLarge
+	}
+	switch e := err.(type) {
+	case httptransport.Error:
+		switch e.Domain {
+		case httptransport.DomainDecode:
+			switch sentinel(e.Err) {
+			case ErrUnsupportedMediaType, ErrNotAcceptable, ErrMethodNotAllowed, ErrBodyTooLarge:
+				return err2code(e.Err)
+			}
+			return http.StatusBadRequest
+		case httptransport.DomainDo:
+			return err2code(e.Err)
+		}
+	}
+	return http.StatusInternalServerError
+}
+
+func err2errcode(err error) string {
+	if code, ok := errorCodes[sentinel(err)]; ok {
+		return code
+	}
+	switch e := err.(type) {
+	case httptransport.Error:
+		switch e.Domain {
+		case httptransport.DomainDecode:
+			if code, ok := errorCodes[sentinel(e.Err)]; ok {
+				return code
+			}
+			return codeDecodeError
+		case httptransport.DomainDo:
+			return err2errcode(e.Err)
+		}
+	}
+	return codeInternalError
+}
+
+// instanceFailed reports whether a non-200 response is a failure of the
+// instance that served it, e.g. because it's overloaded or its circuit breaker
+// is open, rather than of the request, so another instance may succeed.
+func instanceFailed(r *http.Response) bool {
+	return r.StatusCode >= 500 || r.StatusCode == http.StatusTooManyRequests
+}
+
+// errorDecoder reads the error envelope written by errorEncoder from a non-200
+// response body. Codes that correspond to known errors are mapped back to the
+// exported error values, so they may be compared directly; other errors are
+// reconstructed from their message.
+func errorDecoder(r *http.Response) error {
+	var e errorEnvelope
+	if err := json.NewDecoder(r.Body).Decode(&e); err != nil || e.Error == "" {
+		return errors.New(r.Status)
+	}
+	for err, code := range errorCodes {
+		if code == e.Code {
+			return err
+		}
+	}
+	return errors.New(e.Error)
+}
@@ -3,9 +3,14 @@ package http
 import (
 	"bytes"
 	"encoding/json"
-	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"mime"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/tracing/opentracing"
@@ -15,109 +20,357 @@ import (
 	"golang.org/x/net/context"
 
 	"github.com/peterbourgon/go-microservices/addsvc/pkg/endpoints"
-	"github.com/peterbourgon/go-microservices/addsvc/pkg/service"
+	"github.com/peterbourgon/go-microservices/pkg/health"
+	"github.com/peterbourgon/go-microservices/pkg/httpcontext"
 )
 
+// Option sets an optional parameter of the handler.
+type Option func(*handlerOptions)
+
+type handlerOptions struct {
+	maxBodySize int64
+	shutdown    *health.Flag
+}
+
+// MaxBodySize sets the maximum size of request bodies, in bytes. Larger
+// requests fail with ErrBodyTooLarge. By default, it's DefaultMaxBodySize.
+func MaxBodySize(n int64) Option {
+	return func(o *handlerOptions) { o.maxBodySize = n }
+}
+
+// Shutdown sets the flag that fails the readiness check once the service has
+// begun shutting down. By default, readiness fails only while a circuit
+// breaker is open.
+func Shutdown(f *health.Flag) Option {
+	return func(o *handlerOptions) { o.shutdown = f }
+}
+
 // NewHandler returns a handler that makes a set of endpoints available on
-// predefined paths.
-func NewHandler(ctx context.Context, endpoints endpoints.Endpoints, logger log.Logger, trace stdopentracing.Tracer) http.Handler {
-	options := []httptransport.ServerOption{
-		httptransport.ServerErrorEncoder(errorEncoder),
-		httptransport.ServerErrorLogger(logger),
+// predefined paths: the API routes, which are described by the OpenAPI
+// document served at /openapi.json, and /metrics. Liveness and readiness
+// checks are served at /healthz and /readyz.
+func NewHandler(ctx context.Context, endpoints endpoints.Endpoints, logger log.Logger, trace stdopentracing.Tracer, opts ...Option) http.Handler {
+	o := handlerOptions{}
+	for _, opt := range opts {
+		opt(&o)
 	}
 	m := http.NewServeMux()
-	m.Handle("/sum", httptransport.NewServer(
-		ctx,
-		endpoints.SumEndpoint,
-		DecodeSumRequest,
-		EncodeGenericResponse,
-		append(options, httptransport.ServerBefore(opentracing.FromHTTPRequest(trace, "Sum", logger)))...,
-	))
-	m.Handle("/concat", httptransport.NewServer(
-		ctx,
-		endpoints.ConcatEndpoint,
-		DecodeConcatRequest,
-		EncodeGenericResponse,
-		append(options, httptransport.ServerBefore(opentracing.FromHTTPRequest(trace, "Concat", logger)))...,
-	))
+	for path, h := range Routes(ctx, endpoints, logger, trace, opts...) {
+		m.Handle(path, h)
+	}
 	m.Handle("/metrics", promhttp.Handler())
+	m.Handle("/openapi.json", OpenAPI())
+
+	// Liveness has no checks: if we can serve it, we're alive. Readiness
+	// fails while any circuit breaker is open, or once we've begun shutting
+	// down.
+	liveness, readiness := health.NewRegistry(), health.NewRegistry()
+	for name, cb := range endpoints.Breakers {
+		readiness.Register(strings.ToLower(name)+"_breaker", health.BreakerCheck(cb))
+	}
+	if o.shutdown != nil {
+		readiness.Register("shutdown", o.shutdown.Check)
+	}
+	m.Handle("/healthz", liveness)
+	m.Handle("/readyz", readiness)
 	return m
 }
 
-func errorEncoder(_ context.Context, err error, w http.ResponseWriter) {
-	w.WriteHeader(err2code(err))
-	json.NewEncoder(w).Encode(errorWrapper{Error: err.Error()})
+// Routes returns the handlers of the API routes that NewHandler serves, by
+// path. Each of them should be described by the OpenAPI document.
+func Routes(ctx context.Context, endpoints endpoints.Endpoints, logger log.Logger, trace stdopentracing.Tracer, opts ...Option) map[string]http.Handler {
+	o := handlerOptions{maxBodySize: DefaultMaxBodySize}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	options := []httptransport.ServerOption{
+		httptransport.ServerErrorEncoder(errorEncoder),
+		httptransport.ServerErrorLogger(logger),
+		httptransport.ServerAfter(httpcontext.SetRequestIDHeader),
+	}
+	// ServerBefore replaces, rather than adds to, earlier request funcs, so
+	// each route's are given at once.
+	before := func(method string, codecs []*bodyCodec, config httptransport.RequestFunc) httptransport.ServerOption {
+		return httptransport.ServerBefore(
+			opentracing.FromHTTPRequest(trace, method, logger),
+			httpcontext.PopulateRequestID,
+			httpcontext.PopulateCaller,
+			negotiate(codecs...),
+			limitBody(o.maxBodySize),
+			config,
+		)
+	}
+	return map[string]http.Handler{
+		"/sum": httptransport.NewServer(
+			ctx,
+			endpoints.SumEndpoint,
+			DecodeSumRequest,
+			EncodeGenericResponse,
+			append(options, before("Sum", allCodecs, withMethodConfig(endpoints.Config.Sum)))...,
+		),
+		"/sum/batch": httptransport.NewServer(
+			ctx,
+			endpoints.SumBatchEndpoint,
+			DecodeSumBatchRequest,
+			EncodeBatchResponse,
+			append(options, before("SumBatch", batchCodecs, withMethodConfig(endpoints.Config.Sum)))...,
+		),
+		"/sum/big": httptransport.NewServer(
+			ctx,
+			endpoints.SumBigEndpoint,
+			DecodeSumBigRequest,
+			EncodeGenericResponse,
+			append(options, before("SumBig", allCodecs, withMethodConfig(endpoints.Config.Sum)))...,
+		),
+		"/sum/many": httptransport.NewServer(
+			ctx,
+			endpoints.SumManyEndpoint,
+			DecodeSumManyRequest,
+			EncodeGenericResponse,
+			append(options, before("SumMany", allCodecs, withMethodConfig(endpoints.Config.Sum)))...,
+		),
+		"/concat": httptransport.NewServer(
+			ctx,
+			endpoints.ConcatEndpoint,
+			DecodeConcatRequest,
+			EncodeGenericResponse,
+			append(options, before("Concat", allCodecs, withMethodConfig(endpoints.Config.Concat)))...,
+		),
+		"/concat/batch": httptransport.NewServer(
+			ctx,
+			endpoints.ConcatBatchEndpoint,
+			DecodeConcatBatchRequest,
+			EncodeBatchResponse,
+			append(options, before("ConcatBatch", batchCodecs, withMethodConfig(endpoints.Config.Concat)))...,
+		),
+	}
 }
 
-func err2code(err error) int {
-	switch err {
-	case service.ErrTwoZeroes, service.ErrMaxSizeExceeded, service.ErrIntOverflow:
-		return http.StatusBadRequest
+// DecodeSumRequest is a transport/http.DecodeRequestFunc that decodes a sum
+// request. GET requests carry the operands a and b in the query string. POST
+// requests carry them in a form-encoded body, or a body in the negotiated media
+// type, which is JSON by default. Primarily useful in a server.
+func DecodeSumRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var req endpoints.SumRequest
+	values, err := requestValues(ctx, r)
+	if err != nil {
+		return req, err
 	}
-	switch e := err.(type) {
-	case httptransport.Error:
-		switch e.Domain {
-		case httptransport.DomainDecode:
-			return http.StatusBadRequest
-		case httptransport.DomainDo:
-			return err2code(e.Err)
+	if values == nil {
+		err := decodeRequest(ctx, r, &req)
+		return req, err
+	}
+	if req.A, err = intValue(values, "a"); err != nil {
+		return req, err
+	}
+	if req.B, err = intValue(values, "b"); err != nil {
+		return req, err
+	}
+	return req, nil
+}
+
+// DecodeSumBigRequest is a transport/http.DecodeRequestFunc that decodes an
+// arbitrary-precision sum request from the HTTP request body, in the
+// negotiated media type. Operands are given as strings of decimal digits.
+// Primarily useful in a server.
+func DecodeSumBigRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var req endpoints.SumBigRequest
+	err := decodeRequest(ctx, r, &req)
+	return req, err
+}
+
+// ndjsonContentType is the media type of newline-delimited JSON streams.
+const ndjsonContentType = "application/x-ndjson"
+
+// DecodeSumManyRequest is a transport/http.DecodeRequestFunc that decodes a
+// variadic sum request from the HTTP request body. If the request has an
+// application/x-ndjson content type, the body is read as a stream of JSON
+// numbers, one operand per line; otherwise, it's a sum many request in the
+// negotiated media type. Like any other body, the stream is bounded by the
+// maximum body size. Primarily useful in a server.
+func DecodeSumManyRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var req endpoints.SumManyRequest
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != ndjsonContentType {
+		err := decodeRequest(ctx, r, &req)
+		return req, err
+	}
+	if err := acceptable(ctx); err != nil {
+		return req, err
+	}
+	dec := json.NewDecoder(r.Body)
+	for {
+		var operand int
+		err := dec.Decode(&operand)
+		if err == io.EOF {
+			return req, nil
 		}
+		if err != nil {
+			if e, ok := err.(*json.UnmarshalTypeError); ok {
+				return req, invalid(fmt.Sprintf("operands[%d]", len(req.Operands)), "must be %s, not %s", e.Type, e.Value)
+			}
+			return req, bodyError(err)
+		}
+		req.Operands = append(req.Operands, operand)
 	}
-	return http.StatusInternalServerError
 }
 
-func errorDecoder(r *http.Response) error {
-	var w errorWrapper
-	if err := json.NewDecoder(r.Body).Decode(&w); err != nil {
-		return err
+// DecodeConcatRequest is a transport/http.DecodeRequestFunc that decodes a
+// concat request. Like DecodeSumRequest, it reads the query string of GET
+// requests, and form-encoded or negotiated bodies of POST requests. Primarily
+// useful in a server.
+func DecodeConcatRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var req endpoints.ConcatRequest
+	values, err := requestValues(ctx, r)
+	if err != nil {
+		return req, err
+	}
+	if values == nil {
+		err := decodeRequest(ctx, r, &req)
+		return req, err
+	}
+	if req.A, err = stringValue(values, "a"); err != nil {
+		return req, err
+	}
+	if req.B, err = stringValue(values, "b"); err != nil {
+		return req, err
+	}
+	return req, nil
+}
+
+// formContentType is the media type of form-encoded request bodies.
+const formContentType = "application/x-www-form-urlencoded"
+
+// requestValues returns the parameters of a GET request from its query string,
+// or of a POST request from its form-encoded body. It returns nil values for
+// GET requests without a query string, and POST requests with any other body,
+// which should be decoded as usual. Other methods fail with a
+// MethodNotAllowedError.
+func requestValues(ctx context.Context, r *http.Request) (url.Values, error) {
+	switch r.Method {
+	case "GET":
+		if r.URL.RawQuery == "" {
+			return nil, nil // e.g. the JSON body that GET has always accepted
+		}
+		if err := acceptable(ctx); err != nil {
+			return nil, err
+		}
+		return r.URL.Query(), nil
+	case "POST":
+		if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != formContentType {
+			return nil, nil
+		}
+		if err := acceptable(ctx); err != nil {
+			return nil, err
+		}
+		if err := r.ParseForm(); err != nil {
+			return nil, bodyError(err)
+		}
+		return r.PostForm, nil
 	}
-	return errors.New(w.Error)
+	return nil, &MethodNotAllowedError{Allowed: []string{"GET", "POST"}}
 }
 
-type errorWrapper struct {
-	Error string `json:"error"`
+func stringValue(values url.Values, key string) (string, error) {
+	v, ok := values[key]
+	if !ok || len(v) == 0 {
+		return "", invalid(key, "required")
+	}
+	return v[0], nil
 }
 
-// DecodeSumRequest is a transport/http.DecodeRequestFunc that decodes a
-// JSON-encoded sum request from the HTTP request body. Primarily useful in a
-// server.
-func DecodeSumRequest(_ context.Context, r *http.Request) (interface{}, error) {
-	var req endpoints.SumRequest
-	err := json.NewDecoder(r.Body).Decode(&req)
+func intValue(values url.Values, key string) (int, error) {
+	s, err := stringValue(values, key)
+	if err != nil {
+		return 0, err
+	}
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, invalid(key, "must be an integer, not %q", s)
+	}
+	return i, nil
+}
+
+// DecodeSumBatchRequest is a transport/http.DecodeRequestFunc that decodes an
+// array of sum requests from the HTTP request body, in the negotiated media
+// type. Primarily useful in a server.
+func DecodeSumBatchRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var req endpoints.SumBatchRequest
+	err := decodeRequest(ctx, r, &req)
 	return req, err
 }
 
-// DecodeConcatRequest is a transport/http.DecodeRequestFunc that decodes a
-// JSON-encoded concat request from the HTTP request body. Primarily useful in a
-// server.
-func DecodeConcatRequest(_ context.Context, r *http.Request) (interface{}, error) {
-	var req endpoints.ConcatRequest
-	err := json.NewDecoder(r.Body).Decode(&req)
+// DecodeConcatBatchRequest is a transport/http.DecodeRequestFunc that decodes
+// an array of concat requests from the HTTP request body, in the negotiated
+// media type. Primarily useful in a server.
+func DecodeConcatBatchRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var req endpoints.ConcatBatchRequest
+	err := decodeRequest(ctx, r, &req)
 	return req, err
 }
 
 // DecodeSumResponse is a transport/http.DecodeResponseFunc that decodes a
 // JSON-encoded sum response from the HTTP response body. If the response has a
 // non-200 status code, we will interpret that as an error and attempt to decode
-// the specific error message from the response body. Primarily useful in a
-// client.
+// the specific error message from the response body. If the instance failed,
+// rather than the request, i.e. with a 5xx or 429 status code, the error is
+// returned as an endpoint error, so that it's retried by a load balancer, and
+// counted by a circuit breaker. Otherwise, it's returned in the response's Err
+// field, mirroring the server. Primarily useful in a client.
 func DecodeSumResponse(_ context.Context, r *http.Response) (interface{}, error) {
 	if r.StatusCode != http.StatusOK {
-		return nil, errors.New(r.Status)
+		err := errorDecoder(r)
+		if instanceFailed(r) {
+			return nil, err
+		}
+		return endpoints.SumResponse{Err: err}, nil
 	}
 	var resp endpoints.SumResponse
 	err := json.NewDecoder(r.Body).Decode(&resp)
 	return resp, err
 }
 
+// DecodeSumBigResponse is a transport/http.DecodeResponseFunc that decodes a
+// JSON-encoded arbitrary-precision sum response from the HTTP response body.
+// Errors are handled as in DecodeSumResponse. Primarily useful in a client.
+func DecodeSumBigResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	if r.StatusCode != http.StatusOK {
+		err := errorDecoder(r)
+		if instanceFailed(r) {
+			return nil, err
+		}
+		return endpoints.SumBigResponse{Err: err}, nil
+	}
+	var resp endpoints.SumBigResponse
+	err := json.NewDecoder(r.Body).Decode(&resp)
+	return resp, err
+}
+
+// DecodeSumManyResponse is a transport/http.DecodeResponseFunc that decodes a
+// JSON-encoded variadic sum response from the HTTP response body. Errors are
+// handled as in DecodeSumResponse. Primarily useful in a client.
+func DecodeSumManyResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	if r.StatusCode != http.StatusOK {
+		err := errorDecoder(r)
+		if instanceFailed(r) {
+			return nil, err
+		}
+		return endpoints.SumManyResponse{Err: err}, nil
+	}
+	var resp endpoints.SumManyResponse
+	err := json.NewDecoder(r.Body).Decode(&resp)
+	return resp, err
+}
+
 // DecodeConcatResponse is a transport/http.DecodeResponseFunc that decodes
-// a JSON-encoded concat response from the HTTP response body. If the response
-// has a non-200 status code, we will interpret that as an error and attempt to
-// decode the specific error message from the response body. Primarily useful in
-// a client.
+// a JSON-encoded concat response from the HTTP response body. Errors are
+// handled as in DecodeSumResponse. Primarily useful in a client.
 func DecodeConcatResponse(_ context.Context, r *http.Response) (interface{}, error) {
 	if r.StatusCode != http.StatusOK {
-		return nil, errors.New(r.Status)
+		err := errorDecoder(r)
+		if instanceFailed(r) {
+			return nil, err
+		}
+		return endpoints.ConcatResponse{Err: err}, nil
 	}
 	var resp endpoints.ConcatResponse
 	err := json.NewDecoder(r.Body).Decode(&resp)
@@ -136,11 +389,50 @@ func EncodeGenericRequest(_ context.Context, r *http.Request, request interface{
 }
 
 // EncodeGenericResponse is a transport/http.EncodeResponseFunc that encodes
-// the response as JSON to the response writer. Primarily useful in a server.
+// the response to the response writer, in the media type negotiated from the
+// request's Accept header, which is JSON by default. Primarily useful in a
+// server.
 func EncodeGenericResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
 	if f, ok := response.(endpoints.Failer); ok && f.Failed() != nil {
 		errorEncoder(ctx, f.Failed(), w)
 		return nil
 	}
-	return json.NewEncoder(w).Encode(response)
+	return encodeResponse(ctx, w, response)
+}
+
+// EncodeBatchResponse is a transport/http.EncodeResponseFunc that encodes a
+// batch response as an array to the response writer, in the negotiated media
+// type. Each item holds either a result, or an error with its message and code
+// as in the error envelope. The response as a whole succeeds, even if some
+// items fail. Primarily useful in a server.
+func EncodeBatchResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	var items []batchItem
+	switch resp := response.(type) {
+	case endpoints.SumBatchResponse:
+		items = make([]batchItem, len(resp))
+		for i, r := range resp {
+			items[i] = newBatchItem(r.V, r.Err)
+		}
+	case endpoints.ConcatBatchResponse:
+		items = make([]batchItem, len(resp))
+		for i, r := range resp {
+			items[i] = newBatchItem(r.V, r.Err)
+		}
+	default:
+		return fmt.Errorf("unexpected batch response type %T", response)
+	}
+	return encodeResponse(ctx, w, items)
+}
+
+type batchItem struct {
+	V     interface{} `json:"v,omitempty"`
+	Error string      `json:"error,omitempty"`
+	Code  string      `json:"code,omitempty"`
+}
+
+func newBatchItem(v interface{}, err error) batchItem {
+	if err != nil {
+		return batchItem{Error: err.Error(), Code: err2errcode(err)}
+	}
+	return batchItem{V: v}
 }
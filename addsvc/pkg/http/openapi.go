@@ -0,0 +1,103 @@
+package http
+
+import (
+	"github.com/peterbourgon/go-microservices/addsvc/pkg/endpoints"
+	"github.com/peterbourgon/go-microservices/pkg/openapi"
+)
+
+// OpenAPI returns the OpenAPI document describing the routes served by
+// NewHandler. Schemas are generated from the request and response types in
+// package endpoints, so they follow changes to those types; the examples are
+// exercised by the drift test, so they must succeed against a fresh service
+// with the default config, rate limits included.
+func OpenAPI() *openapi.Document {
+	d := openapi.New("addsvc", "1.0.0")
+	responses := func(description string, v interface{}, protobufMessage string) map[string]openapi.Response {
+		ok := openapi.JSONResponse(description, v, msgpackCodec.mediaType)
+		if protobufMessage != "" {
+			ok.Content[protobufCodec.mediaType] = protobufMediaType(protobufMessage)
+		}
+		failed := openapi.JSONResponse("The error envelope.", errorEnvelope{}, msgpackCodec.mediaType)
+		failed.Content[protobufCodec.mediaType] = protobufMediaType("pb.Error")
+		return map[string]openapi.Response{"200": ok, "default": failed}
+	}
+	body := func(example interface{}, protobufMessage string, mediaTypes ...string) *openapi.RequestBody {
+		b := openapi.JSONBody(example, append([]string{msgpackCodec.mediaType}, mediaTypes...)...)
+		if protobufMessage != "" {
+			b.Content[protobufCodec.mediaType] = protobufMediaType(protobufMessage)
+		}
+		return b
+	}
+
+	d.Add("GET", "/sum", &openapi.Operation{
+		Summary:     "Sums two integers given in the query string.",
+		OperationID: "sumQuery",
+		Parameters: []openapi.Parameter{
+			openapi.QueryParameter("a", true, 1),
+			openapi.QueryParameter("b", true, 2),
+		},
+		Responses: responses("The sum.", endpoints.SumResponse{}, "pb.SumReply"),
+	})
+	d.Add("POST", "/sum", &openapi.Operation{
+		Summary:     "Sums two integers.",
+		OperationID: "sum",
+		RequestBody: body(endpoints.SumRequest{A: 1, B: 2}, "pb.SumRequest", formContentType),
+		Responses:   responses("The sum.", endpoints.SumResponse{}, "pb.SumReply"),
+	})
+	d.Add("POST", "/sum/batch", &openapi.Operation{
+		Summary:     "Sums each pair of integers. Each pair counts against the rate limit.",
+		OperationID: "sumBatch",
+		RequestBody: body(endpoints.SumBatchRequest{{A: 1, B: 2}, {A: 3, B: 4}}, ""),
+		Responses:   responses("The sum or error of each pair, in order.", []batchItem{}, ""),
+	})
+	d.Add("POST", "/sum/big", &openapi.Operation{
+		Summary:     "Sums two decimal integers of arbitrary size.",
+		OperationID: "sumBig",
+		RequestBody: body(endpoints.SumBigRequest{A: "99999999999999999999", B: "1"}, "pb.SumBigRequest"),
+		Responses:   responses("The decimal sum.", endpoints.SumBigResponse{}, "pb.SumBigReply"),
+	})
+	sumMany := body(endpoints.SumManyRequest{Operands: []int{1, 2, 3}}, "pb.SumManyRequest")
+	sumMany.Content[ndjsonContentType] = openapi.MediaType{Schema: &openapi.Schema{
+		Type:        "integer",
+		Format:      "int64",
+		Description: "A stream of operands, one JSON number per line.",
+	}}
+	d.Add("POST", "/sum/many", &openapi.Operation{
+		Summary:     "Sums any number of integers.",
+		OperationID: "sumMany",
+		RequestBody: sumMany,
+		Responses:   responses("The sum.", endpoints.SumManyResponse{}, "pb.SumManyReply"),
+	})
+	d.Add("GET", "/concat", &openapi.Operation{
+		Summary:     "Concatenates two strings given in the query string.",
+		OperationID: "concatQuery",
+		Parameters: []openapi.Parameter{
+			openapi.QueryParameter("a", true, "1"),
+			openapi.QueryParameter("b", true, "2"),
+		},
+		Responses: responses("The concatenation.", endpoints.ConcatResponse{}, "pb.ConcatReply"),
+	})
+	d.Add("POST", "/concat", &openapi.Operation{
+		Summary:     "Concatenates two strings.",
+		OperationID: "concat",
+		RequestBody: body(endpoints.ConcatRequest{A: "1", B: "2"}, "pb.ConcatRequest", formContentType),
+		Responses:   responses("The concatenation.", endpoints.ConcatResponse{}, "pb.ConcatReply"),
+	})
+	d.Add("POST", "/concat/batch", &openapi.Operation{
+		Summary:     "Concatenates each pair of strings. Each pair counts against the rate limit.",
+		OperationID: "concatBatch",
+		RequestBody: body(endpoints.ConcatBatchRequest{{A: "1", B: "2"}, {A: "3", B: "4"}}, ""),
+		Responses:   responses("The concatenation or error of each pair, in order.", []batchItem{}, ""),
+	})
+	return d
+}
+
+// protobufMediaType describes a protobuf body holding the message from
+// package pb, which OpenAPI can't describe any further.
+func protobufMediaType(message string) openapi.MediaType {
+	return openapi.MediaType{Schema: &openapi.Schema{
+		Type:        "string",
+		Format:      "binary",
+		Description: "A protobuf-encoded " + message + " message.",
+	}}
+}
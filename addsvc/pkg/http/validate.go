@@ -0,0 +1,118 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	httptransport "github.com/go-kit/kit/transport/http"
+	"golang.org/x/net/context"
+
+	"github.com/peterbourgon/go-microservices/pkg/openapi"
+)
+
+// DefaultMaxBodySize is the maximum size of request bodies, in bytes, unless
+// set with MaxBodySize.
+const DefaultMaxBodySize = 1 << 20
+
+// limitBody returns a transport/http.RequestFunc that limits the request body
+// to n bytes. Reading beyond that fails, and decoders return ErrBodyTooLarge.
+func limitBody(n int64) httptransport.RequestFunc {
+	return func(ctx context.Context, r *http.Request) context.Context {
+		r.Body = http.MaxBytesReader(nil, r.Body, n)
+		return ctx
+	}
+}
+
+// errMaxBytes is the message of the error returned by http.MaxBytesReader
+// once the limit is exceeded. It's compared by message, as older versions of
+// package http don't export a type for it.
+const errMaxBytes = "http: request body too large"
+
+// bodyError maps an error reading the request body to ErrBodyTooLarge, if
+// that's what it is.
+func bodyError(err error) error {
+	if err != nil && err.Error() == errMaxBytes {
+		return ErrBodyTooLarge
+	}
+	return err
+}
+
+// decodeJSON strictly decodes a single JSON value into v. Unknown fields, and
+// any data after the value, are errors. Errors that concern a single field
+// are returned as a ValidationError.
+func decodeJSON(r io.Reader, v interface{}) error {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return jsonError(err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		if err = bodyError(err); err == ErrBodyTooLarge {
+			return err
+		}
+		return errors.New("unexpected data after request body")
+	}
+	return nil
+}
+
+// unknownFieldPrefix starts the message of the error returned by a
+// json.Decoder for unknown fields. There's no type for it.
+const unknownFieldPrefix = "json: unknown field "
+
+// jsonError converts an error decoding JSON into a ValidationError, if it
+// concerns a single field.
+func jsonError(err error) error {
+	if e, ok := err.(*json.UnmarshalTypeError); ok && e.Field != "" {
+		return invalid(e.Field, "must be %s, not %s", e.Type, e.Value)
+	}
+	if msg := err.Error(); strings.HasPrefix(msg, unknownFieldPrefix) {
+		if name, unquoteErr := strconv.Unquote(strings.TrimPrefix(msg, unknownFieldPrefix)); unquoteErr == nil {
+			return invalid(name, "unknown field")
+		}
+	}
+	return bodyError(err)
+}
+
+// missingFields compares the request v with a generic decoding of the same
+// body, i.e. maps and slices, and returns an error for each field of v that
+// wasn't present. Fields tagged omitempty are optional. Fields of nested
+// requests, i.e. batch items, are prefixed with their index.
+func missingFields(v interface{}, generic interface{}, prefix string) []FieldError {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	var errs []FieldError
+	switch rv.Kind() {
+	case reflect.Struct:
+		m, ok := generic.(map[string]interface{})
+		if !ok {
+			return nil // type errors are reported by the decoder
+		}
+		for i := 0; i < rv.NumField(); i++ {
+			name, omitempty, ok := openapi.FieldName(rv.Type().Field(i))
+			if ok && !omitempty && !hasKey(m, name) {
+				errs = append(errs, FieldError{Field: prefix + name, Error: "required"})
+			}
+		}
+	case reflect.Slice:
+		s, _ := generic.([]interface{})
+		for i := 0; i < rv.Len() && i < len(s); i++ {
+			errs = append(errs, missingFields(rv.Index(i).Interface(), s[i], fmt.Sprintf("%s[%d].", prefix, i))...)
+		}
+	}
+	return errs
+}
+
+// hasKey reports whether m has the key, ignoring case, as package json does.
+func hasKey(m map[string]interface{}, key string) bool {
+	for k := range m {
+		if strings.EqualFold(k, key) {
+			return true
+		}
+	}
+	return false
+}
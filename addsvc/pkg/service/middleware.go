@@ -1,6 +1,8 @@
 package service
 
 import (
+	"unicode/utf8"
+
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/metrics"
 	"golang.org/x/net/context"
@@ -29,6 +31,20 @@ func (mw loggingMiddleware) Sum(ctx context.Context, a, b int) (v int, err error
 	return mw.next.Sum(ctx, a, b)
 }
 
+func (mw loggingMiddleware) SumBig(ctx context.Context, a, b string) (v string, err error) {
+	defer func() {
+		mw.logger.Log("method", "SumBig", "a", a, "b", b, "v", v, "err", err)
+	}()
+	return mw.next.SumBig(ctx, a, b)
+}
+
+func (mw loggingMiddleware) SumMany(ctx context.Context, operands []int) (v int, err error) {
+	defer func() {
+		mw.logger.Log("method", "SumMany", "n", len(operands), "v", v, "err", err)
+	}()
+	return mw.next.SumMany(ctx, operands)
+}
+
 func (mw loggingMiddleware) Concat(ctx context.Context, a, b string) (v string, err error) {
 	defer func() {
 		mw.logger.Log("method", "Concat", "a", a, "b", b, "v", v, "err", err)
@@ -38,7 +54,8 @@ func (mw loggingMiddleware) Concat(ctx context.Context, a, b string) (v string,
 
 // InstrumentingMiddleware returns a service middleware that instruments
 // the number of integers summed and characters concatenated over the lifetime of
-// the service.
+// the service. Integers are counted per operand of each successful sum, and
+// characters as runes, regardless of how Concat measures its limit.
 func InstrumentingMiddleware(ints, chars metrics.Counter) Middleware {
 	return func(next Service) Service {
 		return instrumentingMiddleware{
@@ -57,12 +74,30 @@ type instrumentingMiddleware struct {
 
 func (mw instrumentingMiddleware) Sum(ctx context.Context, a, b int) (int, error) {
 	v, err := mw.next.Sum(ctx, a, b)
-	mw.ints.Add(float64(v))
+	if err == nil {
+		mw.ints.Add(2)
+	}
+	return v, err
+}
+
+func (mw instrumentingMiddleware) SumBig(ctx context.Context, a, b string) (string, error) {
+	v, err := mw.next.SumBig(ctx, a, b)
+	if err == nil {
+		mw.ints.Add(2)
+	}
+	return v, err
+}
+
+func (mw instrumentingMiddleware) SumMany(ctx context.Context, operands []int) (int, error) {
+	v, err := mw.next.SumMany(ctx, operands)
+	if err == nil {
+		mw.ints.Add(float64(len(operands)))
+	}
 	return v, err
 }
 
 func (mw instrumentingMiddleware) Concat(ctx context.Context, a, b string) (string, error) {
 	v, err := mw.next.Concat(ctx, a, b)
-	mw.chars.Add(float64(len(v)))
+	mw.chars.Add(float64(utf8.RuneCountInString(v)))
 	return v, err
 }
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/rivo/uniseg"
+	"golang.org/x/net/context"
+)
+
+// Option sets an optional parameter of the basic service.
+type Option func(*basicService)
+
+// MaxConcatSize sets the maximum size of the result of the Concat method, as
+// measured in the unit set by ConcatUnit. By default, it's
+// DefaultMaxConcatSize.
+func MaxConcatSize(n int) Option {
+	return func(s *basicService) { s.maxConcatSize = n }
+}
+
+// ConcatUnit sets the unit in which the size of the result of the Concat
+// method is measured. By default, it's Bytes.
+func ConcatUnit(u Unit) Option {
+	return func(s *basicService) { s.concatUnit = u }
+}
+
+// UppercaseConcat delegates uppercasing the result of the Concat method to u,
+// e.g. a stringsvc client. The maximum size applies to the uppercased result.
+// By default, results aren't uppercased.
+func UppercaseConcat(u Uppercaser) Option {
+	return func(s *basicService) { s.uppercaser = u }
+}
+
+// Uppercaser uppercases strings.
+type Uppercaser interface {
+	Uppercase(ctx context.Context, s string) (string, error)
+}
+
+// Unit is a way of measuring the length of a string.
+type Unit int
+
+const (
+	// Bytes counts the bytes of the UTF-8 encoding.
+	Bytes Unit = iota
+
+	// Runes counts Unicode code points.
+	Runes
+
+	// Graphemes counts extended grapheme clusters, i.e. user-perceived
+	// characters, so e.g. a flag or an emoji ZWJ sequence counts as one.
+	Graphemes
+)
+
+// ParseUnit returns the Unit with the given name, as returned by String.
+func ParseUnit(s string) (Unit, error) {
+	for _, u := range []Unit{Bytes, Runes, Graphemes} {
+		if s == u.String() {
+			return u, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown unit %q", s)
+}
+
+// Set implements flag.Value.
+func (u *Unit) Set(s string) error {
+	v, err := ParseUnit(s)
+	if err != nil {
+		return err
+	}
+	*u = v
+	return nil
+}
+
+func (u Unit) String() string {
+	switch u {
+	case Bytes:
+		return "bytes"
+	case Runes:
+		return "runes"
+	case Graphemes:
+		return "graphemes"
+	}
+	return fmt.Sprintf("Unit(%d)", int(u))
+}
+
+// Len returns the length of s in the unit.
+func (u Unit) Len(s string) int {
+	switch u {
+	case Runes:
+		return utf8.RuneCountInString(s)
+	case Graphemes:
+		var n int
+		for g := uniseg.NewGraphemes(s); g.Next(); {
+			n++
+		}
+		return n
+	}
+	return len(s)
+}
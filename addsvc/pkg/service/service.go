@@ -2,6 +2,8 @@ package service
 
 import (
 	"errors"
+	"fmt"
+	"math/big"
 
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/metrics"
@@ -11,14 +13,17 @@ import (
 // Service describes a service that adds things together.
 type Service interface {
 	Sum(ctx context.Context, a, b int) (int, error)
+	SumBig(ctx context.Context, a, b string) (string, error)
+	SumMany(ctx context.Context, operands []int) (int, error)
 	Concat(ctx context.Context, a, b string) (string, error)
 }
 
 // New returns a basic Service with all of the expected middlewares wired in.
-func New(logger log.Logger, ints, chars metrics.Counter) Service {
+// The options are passed to NewBasicService.
+func New(logger log.Logger, ints, chars metrics.Counter, options ...Option) Service {
 	var svc Service
 	{
-		svc = NewBasicService()
+		svc = NewBasicService(options...)
 		svc = LoggingMiddleware(logger)(svc)
 		svc = InstrumentingMiddleware(ints, chars)(svc)
 	}
@@ -35,23 +40,55 @@ var (
 	// difference. In a real service, this probably wouldn't be the case.
 	ErrIntOverflow = errors.New("integer overflow")
 
-	// ErrMaxSizeExceeded protects the Concat method.
+	// ErrInvalidNumber is returned by the SumBig method when an operand isn't
+	// a decimal integer.
+	ErrInvalidNumber = errors.New("invalid decimal integer")
+
+	// ErrMaxSizeExceeded protects the Concat method. The service returns a
+	// MaxSizeError, which describes it in detail; transports map that back to
+	// ErrMaxSizeExceeded, so clients may compare against it.
 	ErrMaxSizeExceeded = errors.New("result exceeds maximum size")
 )
 
+// MaxSizeError is returned by the Concat method when the result would exceed
+// the maximum size.
+type MaxSizeError struct {
+	Limit int
+	Size  int
+	Unit  Unit
+}
+
+func (e MaxSizeError) Error() string {
+	return fmt.Sprintf("%v: %d %s, limit %d", ErrMaxSizeExceeded, e.Size, e.Unit, e.Limit)
+}
+
 // NewBasicService returns a naïve, stateless implementation of Service.
-func NewBasicService() Service {
-	return basicService{}
+func NewBasicService(options ...Option) Service {
+	s := basicService{
+		maxConcatSize: DefaultMaxConcatSize,
+		concatUnit:    Bytes,
+	}
+	for _, option := range options {
+		option(&s)
+	}
+	return s
 }
 
-type basicService struct{}
+type basicService struct {
+	maxConcatSize int
+	concatUnit    Unit
+	uppercaser    Uppercaser
+}
 
 const (
 	intMax = 1<<31 - 1
 	intMin = -(intMax + 1)
-	maxLen = 10
 )
 
+// DefaultMaxConcatSize is the maximum size of the result of the Concat method,
+// unless set with MaxConcatSize.
+const DefaultMaxConcatSize = 10
+
 func (s basicService) Sum(_ context.Context, a, b int) (int, error) {
 	if a == 0 && b == 0 {
 		return 0, ErrTwoZeroes
@@ -62,10 +99,53 @@ func (s basicService) Sum(_ context.Context, a, b int) (int, error) {
 	return a + b, nil
 }
 
-// Concat implements Service.
-func (s basicService) Concat(_ context.Context, a, b string) (string, error) {
-	if len(a)+len(b) > maxLen {
-		return "", ErrMaxSizeExceeded
+// SumBig implements Service. It's like Sum, but operates on decimal strings
+// of arbitrary precision, so it can't overflow.
+func (s basicService) SumBig(_ context.Context, a, b string) (string, error) {
+	x, ok := new(big.Int).SetString(a, 10)
+	if !ok {
+		return "", ErrInvalidNumber
 	}
-	return a + b, nil
+	y, ok := new(big.Int).SetString(b, 10)
+	if !ok {
+		return "", ErrInvalidNumber
+	}
+	if x.Sign() == 0 && y.Sign() == 0 {
+		return "", ErrTwoZeroes
+	}
+	return x.Add(x, y).String(), nil
+}
+
+// SumMany implements Service. It's like Sum over any number of operands: the
+// sum must not overflow at any step, and at least one operand must be nonzero.
+func (s basicService) SumMany(_ context.Context, operands []int) (int, error) {
+	var v int
+	var nonzero bool
+	for _, x := range operands {
+		if (x > 0 && v > (intMax-x)) || (x < 0 && v < (intMin-x)) {
+			return 0, ErrIntOverflow
+		}
+		v += x
+		nonzero = nonzero || x != 0
+	}
+	if !nonzero {
+		return 0, ErrTwoZeroes
+	}
+	return v, nil
+}
+
+// Concat implements Service. If the result would be larger than the maximum
+// size, it returns a MaxSizeError.
+func (s basicService) Concat(ctx context.Context, a, b string) (string, error) {
+	v := a + b
+	if s.uppercaser != nil && v != "" {
+		var err error
+		if v, err = s.uppercaser.Uppercase(ctx, v); err != nil {
+			return "", err
+		}
+	}
+	if size := s.concatUnit.Len(v); size > s.maxConcatSize {
+		return "", MaxSizeError{Limit: s.maxConcatSize, Size: size, Unit: s.concatUnit}
+	}
+	return v, nil
 }
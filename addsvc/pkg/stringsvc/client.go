@@ -0,0 +1,141 @@
+// Package stringsvc is a client for stringsvc, which addsvc calls to uppercase
+// the results of Concat. The current span and request ID are propagated on
+// each call, so the work done by stringsvc joins the caller's trace and logs.
+package stringsvc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/circuitbreaker"
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/tracing/opentracing"
+	httptransport "github.com/go-kit/kit/transport/http"
+	stdopentracing "github.com/opentracing/opentracing-go"
+	"github.com/sony/gobreaker"
+	"golang.org/x/net/context"
+
+	"github.com/peterbourgon/go-microservices/pkg/httpcontext"
+)
+
+// ErrEmpty is returned by Uppercase when the string is empty. It mirrors the
+// error of the same name in stringsvc.
+var ErrEmpty = errors.New("empty string")
+
+// Client calls a remote stringsvc over HTTP. It implements
+// service.Uppercaser.
+type Client struct {
+	uppercase endpoint.Endpoint
+}
+
+// Option sets an optional parameter of the Client.
+type Option func(*clientOptions)
+
+type clientOptions struct {
+	timeout time.Duration
+}
+
+// DefaultTimeout is the deadline for each call to stringsvc, unless set with
+// Timeout.
+const DefaultTimeout = time.Second
+
+// Timeout sets the deadline for each call to stringsvc. Calls that take longer
+// fail, and count against the client's circuit breaker. By default, it's
+// DefaultTimeout.
+func Timeout(d time.Duration) Option {
+	return func(o *clientOptions) { o.timeout = d }
+}
+
+// New returns a Client of the stringsvc living at the remote base URL. If the
+// URL has no scheme, http is assumed, so "host:port" is accepted. Calls fail
+// fast while stringsvc is failing, via a circuit breaker, rather than each
+// waiting out the timeout.
+func New(baseURL string, logger log.Logger, trace stdopentracing.Tracer, opts ...Option) (*Client, error) {
+	o := clientOptions{timeout: DefaultTimeout}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if !strings.Contains(baseURL, "://") {
+		baseURL = "http://" + baseURL
+	}
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = strings.TrimRight(u.Path, "/") + "/uppercase"
+
+	var uppercaseEndpoint endpoint.Endpoint
+	{
+		uppercaseEndpoint = httptransport.NewClient(
+			"POST",
+			u,
+			encodeRequest,
+			decodeUppercaseResponse,
+			httptransport.SetClient(&http.Client{Timeout: o.timeout}),
+			httptransport.ClientBefore(opentracing.ToHTTPRequest(trace, logger), httpcontext.SetRequestID),
+		).Endpoint()
+		uppercaseEndpoint = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{Name: "stringsvc"}))(uppercaseEndpoint)
+		uppercaseEndpoint = opentracing.TraceClient(trace, "Uppercase")(uppercaseEndpoint)
+	}
+	return &Client{uppercase: uppercaseEndpoint}, nil
+}
+
+// Uppercase returns s in upper case, as computed by the remote stringsvc.
+func (c *Client) Uppercase(ctx context.Context, s string) (string, error) {
+	response, err := c.uppercase(ctx, uppercaseRequest{S: s})
+	if err != nil {
+		return "", err
+	}
+	resp := response.(uppercaseResponse)
+	switch resp.Err {
+	case "":
+		return resp.V, nil
+	case ErrEmpty.Error():
+		return "", ErrEmpty
+	default:
+		return "", errors.New(resp.Err)
+	}
+}
+
+func encodeRequest(_ context.Context, r *http.Request, request interface{}) error {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(request); err != nil {
+		return err
+	}
+	r.Header.Set("Content-Type", "application/json; charset=utf-8")
+	r.Body = ioutil.NopCloser(&buf)
+	return nil
+}
+
+// maxErrorBody is how much of an error response's body is kept in the error.
+const maxErrorBody = 1 << 10
+
+func decodeUppercaseResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	if r.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(io.LimitReader(r.Body, maxErrorBody))
+		return nil, fmt.Errorf("stringsvc: %s: %s", r.Status, bytes.TrimSpace(body))
+	}
+	var resp uppercaseResponse
+	if err := json.NewDecoder(r.Body).Decode(&resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+type uppercaseRequest struct {
+	S string `json:"s"`
+}
+
+type uppercaseResponse struct {
+	V   string `json:"v"`
+	Err string `json:"err,omitempty"`
+}
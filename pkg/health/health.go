@@ -0,0 +1,130 @@
+// Package health provides a registry of named health checks, and HTTP handlers
+// that report on them, suitable for liveness and readiness probes.
+package health
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sony/gobreaker"
+)
+
+// Check reports the health of a single component. A nil error means healthy.
+type Check func() error
+
+// Registry collects named checks. It's safe for concurrent use.
+type Registry struct {
+	mtx    sync.RWMutex
+	checks map[string]Check
+}
+
+// NewRegistry returns an empty Registry. A Registry without checks is always
+// healthy.
+func NewRegistry() *Registry {
+	return &Registry{checks: map[string]Check{}}
+}
+
+// Register adds a check under the given name, replacing any existing check
+// with the same name.
+func (r *Registry) Register(name string, check Check) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.checks[name] = check
+}
+
+// Run runs every check, and returns the results by name, and whether all of
+// them passed.
+func (r *Registry) Run() (results map[string]Result, healthy bool) {
+	r.mtx.RLock()
+	names := make([]string, 0, len(r.checks))
+	for name := range r.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	checks := make([]Check, len(names))
+	for i, name := range names {
+		checks[i] = r.checks[name]
+	}
+	r.mtx.RUnlock()
+
+	results, healthy = map[string]Result{}, true
+	for i, check := range checks {
+		if err := check(); err != nil {
+			results[names[i]] = Result{Status: statusFailing, Error: err.Error()}
+			healthy = false
+			continue
+		}
+		results[names[i]] = Result{Status: statusOK}
+	}
+	return results, healthy
+}
+
+// ServeHTTP runs every check and writes the results as JSON. If any check
+// fails, the status code is 503 Service Unavailable.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	results, healthy := r.Run()
+	report := Report{Status: statusOK, Checks: results}
+	code := http.StatusOK
+	if !healthy {
+		report.Status, code = statusFailing, http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(report)
+}
+
+const (
+	statusOK      = "ok"
+	statusFailing = "failing"
+)
+
+// Report is the body written by a Registry's HTTP handler.
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// Result is the outcome of a single check.
+type Result struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Flag is a check that passes until Fail is called. It's useful to report a
+// state change driven from elsewhere, e.g. a pending shutdown.
+type Flag struct {
+	failed int32
+	err    error
+}
+
+// NewFlag returns a passing Flag, which will report err once it has failed.
+func NewFlag(err error) *Flag {
+	return &Flag{err: err}
+}
+
+// Fail makes the flag's check fail from now on.
+func (f *Flag) Fail() {
+	atomic.StoreInt32(&f.failed, 1)
+}
+
+// Check implements Check.
+func (f *Flag) Check() error {
+	if atomic.LoadInt32(&f.failed) != 0 {
+		return f.err
+	}
+	return nil
+}
+
+// BreakerCheck returns a check that fails while the circuit breaker is open.
+func BreakerCheck(cb *gobreaker.CircuitBreaker) Check {
+	return func() error {
+		if state := cb.State(); state == gobreaker.StateOpen {
+			return fmt.Errorf("circuit breaker %s", state)
+		}
+		return nil
+	}
+}
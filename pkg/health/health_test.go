@@ -0,0 +1,61 @@
+package health
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sony/gobreaker"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	flag := NewFlag(errors.New("shutting down"))
+	r.Register("ok", func() error { return nil })
+	r.Register("shutdown", flag.Check)
+
+	if want, have := http.StatusOK, serve(t, r).Code; want != have {
+		t.Errorf("before Fail: want %d, have %d", want, have)
+	}
+
+	flag.Fail()
+	rec := serve(t, r)
+	if want, have := http.StatusServiceUnavailable, rec.Code; want != have {
+		t.Errorf("after Fail: want %d, have %d", want, have)
+	}
+	var report Report
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "failing", report.Status; want != have {
+		t.Errorf("status: want %q, have %q", want, have)
+	}
+	if want, have := (Result{Status: "ok"}), report.Checks["ok"]; want != have {
+		t.Errorf("ok: want %+v, have %+v", want, have)
+	}
+	if want, have := (Result{Status: "failing", Error: "shutting down"}), report.Checks["shutdown"]; want != have {
+		t.Errorf("shutdown: want %+v, have %+v", want, have)
+	}
+}
+
+func TestBreakerCheck(t *testing.T) {
+	cb := gobreaker.NewCircuitBreaker(gobreaker.Settings{})
+	check := BreakerCheck(cb)
+	if err := check(); err != nil {
+		t.Errorf("closed: want no error, have %v", err)
+	}
+	for i := 0; i < 6; i++ { // trips after more than 5 consecutive failures
+		cb.Execute(func() (interface{}, error) { return nil, errors.New("fail") })
+	}
+	if err := check(); err == nil {
+		t.Error("open: want error, have none")
+	}
+}
+
+func serve(t *testing.T, h http.Handler) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/readyz", nil))
+	return rec
+}
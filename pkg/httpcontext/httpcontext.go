@@ -0,0 +1,99 @@
+// Package httpcontext moves the values that the endpoint middlewares read
+// between HTTP headers and the request context: the caller, for per-caller
+// rate limiting, and the request ID, for logs and error responses.
+package httpcontext
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net"
+	"net/http"
+
+	"golang.org/x/net/context"
+
+	"github.com/peterbourgon/go-microservices/pkg/middleware"
+)
+
+// APIKeyHeader identifies the caller for per-caller rate limiting. Requests
+// without it are identified by their client IP. The key isn't authenticated, so
+// it only tells well-behaved callers apart: a client that sends a new key with
+// every request is never held to a per-caller limit, and may push other
+// callers' buckets out of the cache. Only the overall rate limit holds it back.
+const APIKeyHeader = "X-API-Key"
+
+// RequestIDHeader carries the request ID. If a request has a valid one, e.g.
+// because another service propagated it, it's used; otherwise, one is
+// generated. Either way, it's returned in the response.
+const RequestIDHeader = "X-Request-ID"
+
+// PopulateCaller is a transport/http.RequestFunc that sets the caller key used
+// by the per-caller rate limiter in package middleware.
+func PopulateCaller(ctx context.Context, r *http.Request) context.Context {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		return middleware.WithCaller(ctx, "key:"+key)
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return middleware.WithCaller(ctx, "ip:"+host)
+}
+
+// PopulateRequestID is a transport/http.RequestFunc that sets the request ID
+// in the context. A missing or malformed request ID is replaced with a new one,
+// so that whatever a client sends can't garble logs or response headers.
+func PopulateRequestID(ctx context.Context, r *http.Request) context.Context {
+	id := r.Header.Get(RequestIDHeader)
+	if !validRequestID(id) {
+		id = newRequestID()
+	}
+	return middleware.WithRequestID(ctx, id)
+}
+
+// SetRequestIDHeader is a transport/http.ServerResponseFunc that returns the
+// request ID to the client. ServerResponseFuncs only run after successful
+// requests, so error encoders should call it too.
+func SetRequestIDHeader(ctx context.Context, w http.ResponseWriter) context.Context {
+	if id := middleware.RequestIDFromContext(ctx); id != "" {
+		w.Header().Set(RequestIDHeader, id)
+	}
+	return ctx
+}
+
+// SetRequestID is a transport/http.RequestFunc for clients, which propagates
+// the request ID in the context, if any, to the service being called.
+func SetRequestID(ctx context.Context, r *http.Request) context.Context {
+	if id := middleware.RequestIDFromContext(ctx); id != "" {
+		r.Header.Set(RequestIDHeader, id)
+	}
+	return ctx
+}
+
+// maxRequestIDLength bounds the request IDs accepted from clients.
+const maxRequestIDLength = 64
+
+// validRequestID reports whether the request ID is 1 to maxRequestIDLength
+// letters, digits, dashes, underscores, periods, or colons, which covers
+// e.g. UUIDs and the IDs that newRequestID generates.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b[:])
+}
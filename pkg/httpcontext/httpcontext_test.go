@@ -0,0 +1,51 @@
+package httpcontext
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/peterbourgon/go-microservices/pkg/middleware"
+)
+
+func TestPopulateRequestID(t *testing.T) {
+	for _, testcase := range []struct {
+		header string
+		kept   bool
+	}{
+		{"abc123", true},
+		{"123e4567-e89b-12d3-a456-426614174000", true},
+		{"trace:span.1_2", true},
+		{strings.Repeat("a", maxRequestIDLength), true},
+		{"", false},
+		{strings.Repeat("a", maxRequestIDLength+1), false},
+		{"a b", false},
+		{"a\tb", false},
+		{"<script>", false},
+		{"café", false},
+	} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set(RequestIDHeader, testcase.header)
+		id := middleware.RequestIDFromContext(PopulateRequestID(context.Background(), r))
+		if want, have := testcase.kept, id == testcase.header; want != have {
+			t.Errorf("%q: want kept %v, have %q", testcase.header, want, id)
+		}
+		if !validRequestID(id) {
+			t.Errorf("%q: want a valid request ID, have %q", testcase.header, id)
+		}
+	}
+}
+
+func TestPopulateCaller(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+	if want, have := "ip:192.0.2.1", middleware.CallerFromContext(PopulateCaller(context.Background(), r)); want != have {
+		t.Errorf("without key: want %q, have %q", want, have)
+	}
+	r.Header.Set(APIKeyHeader, "alice")
+	if want, have := "key:alice", middleware.CallerFromContext(PopulateCaller(context.Background(), r)); want != have {
+		t.Errorf("with key: want %q, have %q", want, have)
+	}
+}
@@ -0,0 +1,176 @@
+// Package middleware provides the endpoint middlewares that the services wire
+// around each of their methods: rate limiters, circuit breakers, logging, and
+// instrumentation, and the context values they read.
+package middleware
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/ratelimit"
+	rl "github.com/juju/ratelimit"
+	"github.com/sony/gobreaker"
+)
+
+// MethodConfig collects the rate limiter and circuit breaker parameters for a
+// single method.
+type MethodConfig struct {
+	// Rate is the number of requests per second admitted by the rate limiter.
+	Rate float64
+
+	// Burst is the capacity of the rate limiter's token bucket, i.e. the
+	// number of requests that may be admitted at once.
+	Burst int64
+
+	// CallerRate is the number of requests per second admitted from each
+	// individual caller, in addition to the overall Rate. Zero disables the
+	// per-caller rate limiter. Callers are identified by CallerFromContext,
+	// e.g. by an API key that isn't authenticated, so a caller that changes
+	// its identity evades its limit; only the overall Rate is enforced.
+	CallerRate float64
+
+	// CallerBurst is the capacity of each caller's token bucket.
+	CallerBurst int64
+
+	// BreakerTripRatio is the ratio of failed requests, in (0, 1], at which
+	// the circuit breaker trips. Zero selects the gobreaker default, which
+	// trips after more than 5 consecutive failures.
+	BreakerTripRatio float64
+
+	// BreakerInterval is the cyclic period of the closed state, after which
+	// the circuit breaker clears its counts. Zero never clears them.
+	BreakerInterval time.Duration
+
+	// BreakerTimeout is the period of the open state, after which the circuit
+	// breaker becomes half-open. Zero selects the gobreaker default of 60s.
+	BreakerTimeout time.Duration
+}
+
+// Flags registers flags for each of the parameters in the method config, with
+// the given prefix, using the current values as defaults.
+func (c *MethodConfig) Flags(fs *flag.FlagSet, prefix string) {
+	fs.Float64Var(&c.Rate, prefix+".rate", c.Rate, "rate limit in requests per second")
+	fs.Int64Var(&c.Burst, prefix+".burst", c.Burst, "rate limit burst size")
+	fs.Float64Var(&c.CallerRate, prefix+".caller.rate", c.CallerRate, "per-caller rate limit in requests per second, by unauthenticated API key or client IP (0 to disable)")
+	fs.Int64Var(&c.CallerBurst, prefix+".caller.burst", c.CallerBurst, "per-caller rate limit burst size")
+	fs.Float64Var(&c.BreakerTripRatio, prefix+".breaker.ratio", c.BreakerTripRatio, "failure ratio that trips the circuit breaker (0 for default)")
+	fs.DurationVar(&c.BreakerInterval, prefix+".breaker.interval", c.BreakerInterval, "period after which the closed circuit breaker clears its counts (0 for never)")
+	fs.DurationVar(&c.BreakerTimeout, prefix+".breaker.timeout", c.BreakerTimeout, "period the circuit breaker stays open (0 for default)")
+}
+
+// Bounds of the rates accepted by Validate. The token buckets count time in
+// nanoseconds, so they can't represent rates outside of them.
+const (
+	minRate = 1e-6 // about one request every 11 days
+	maxRate = 1e9  // one request every nanosecond
+)
+
+// Validate returns an error if any of the parameters are nonsensical.
+func (c MethodConfig) Validate() error {
+	if !validRate(c.Rate) {
+		return fmt.Errorf("rate must be from %v to %v, have %v", minRate, maxRate, c.Rate)
+	}
+	if c.Burst < 1 {
+		return fmt.Errorf("burst must be at least 1, have %d", c.Burst)
+	}
+	if c.CallerRate != 0 && !validRate(c.CallerRate) {
+		return fmt.Errorf("caller rate must be 0, or from %v to %v, have %v", minRate, maxRate, c.CallerRate)
+	}
+	if c.CallerRate > 0 && c.CallerBurst < 1 {
+		return fmt.Errorf("caller burst must be at least 1, have %d", c.CallerBurst)
+	}
+	if math.IsNaN(c.BreakerTripRatio) || c.BreakerTripRatio < 0 || c.BreakerTripRatio > 1 {
+		return fmt.Errorf("breaker trip ratio must be between 0 and 1, have %v", c.BreakerTripRatio)
+	}
+	if c.BreakerInterval < 0 {
+		return fmt.Errorf("breaker interval must not be negative, have %v", c.BreakerInterval)
+	}
+	if c.BreakerTimeout < 0 {
+		return fmt.Errorf("breaker timeout must not be negative, have %v", c.BreakerTimeout)
+	}
+	return nil
+}
+
+// validRate reports whether the rate is within bounds. NaN isn't.
+func validRate(rate float64) bool {
+	return rate >= minRate && rate <= maxRate
+}
+
+// minBreakerRequests is the number of requests the circuit breaker must see
+// in an interval before the trip ratio is considered, so that a single early
+// failure doesn't trip it.
+const minBreakerRequests = 10
+
+// Limiter returns the rate limiter for the method, including the per-caller
+// rate limiter if it's enabled. A single Limiter may be shared between
+// endpoints, so that e.g. batching can't bypass the rate limits. The config is
+// assumed to be valid; see Validate.
+func (c MethodConfig) Limiter(maxCallers int) *Limiter {
+	l := &Limiter{bucket: rl.NewBucketWithRate(c.Rate, c.Burst)}
+	if c.CallerRate > 0 {
+		l.callers = newBucketCache(maxCallers, func() *rl.Bucket {
+			return rl.NewBucketWithRate(c.CallerRate, c.CallerBurst)
+		})
+	}
+	return l
+}
+
+// RateLimiter returns an endpoint middleware that charges each request a
+// single token against the method's rate limiters, for methods without
+// batches. Each call returns a middleware with its own limiters. The
+// config is assumed to be valid; see Validate.
+func (c MethodConfig) RateLimiter(maxCallers int) endpoint.Middleware {
+	return c.Limiter(maxCallers).Middleware(Single)
+}
+
+// defaultBreakerTimeout is gobreaker's period of the open state, used if
+// BreakerTimeout is zero.
+const defaultBreakerTimeout = 60 * time.Second
+
+// RetryAfter returns how long a client should wait before retrying a request
+// that the method's middlewares rejected with err: the time for the rate
+// limiter that rejected it to admit another request, or for the circuit
+// breaker to become half-open. It returns zero for any other error.
+func (c MethodConfig) RetryAfter(err error) time.Duration {
+	switch err {
+	case ratelimit.ErrLimited:
+		return interval(c.Rate)
+	case ErrRateLimited:
+		return interval(c.CallerRate)
+	case gobreaker.ErrOpenState, gobreaker.ErrTooManyRequests:
+		if c.BreakerTimeout > 0 {
+			return c.BreakerTimeout
+		}
+		return defaultBreakerTimeout
+	}
+	return 0
+}
+
+// interval returns the time between requests at the rate, or zero if it's not
+// positive.
+func interval(rate float64) time.Duration {
+	if rate <= 0 {
+		return 0
+	}
+	return time.Duration(float64(time.Second) / rate)
+}
+
+// Breaker returns a circuit breaker with the given name, as configured for the
+// method. The config is assumed to be valid; see Validate.
+func (c MethodConfig) Breaker(name string) *gobreaker.CircuitBreaker {
+	settings := gobreaker.Settings{
+		Name:     name,
+		Interval: c.BreakerInterval,
+		Timeout:  c.BreakerTimeout,
+	}
+	if ratio := c.BreakerTripRatio; ratio > 0 {
+		settings.ReadyToTrip = func(counts gobreaker.Counts) bool {
+			return counts.Requests >= minBreakerRequests &&
+				float64(counts.TotalFailures)/float64(counts.Requests) >= ratio
+		}
+	}
+	return gobreaker.NewCircuitBreaker(settings)
+}
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"math"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestMethodConfigValidate(t *testing.T) {
+	valid := MethodConfig{Rate: 1, Burst: 1}
+	for _, testcase := range []struct {
+		name  string
+		mod   func(*MethodConfig)
+		valid bool
+	}{
+		{"default", func(c *MethodConfig) {}, true},
+		{"slowest rate", func(c *MethodConfig) { c.Rate = 1e-6 }, true},
+		{"fastest rate", func(c *MethodConfig) { c.Rate = 1e9 }, true},
+		{"zero rate", func(c *MethodConfig) { c.Rate = 0 }, false},
+		{"negative rate", func(c *MethodConfig) { c.Rate = -1 }, false},
+		{"tiny rate", func(c *MethodConfig) { c.Rate = 1e-300 }, false},
+		{"huge rate", func(c *MethodConfig) { c.Rate = 1e300 }, false},
+		{"NaN rate", func(c *MethodConfig) { c.Rate = math.NaN() }, false},
+		{"infinite rate", func(c *MethodConfig) { c.Rate = math.Inf(1) }, false},
+		{"zero burst", func(c *MethodConfig) { c.Burst = 0 }, false},
+		{"caller rate", func(c *MethodConfig) { c.CallerRate, c.CallerBurst = 1e9, 1 }, true},
+		{"negative caller rate", func(c *MethodConfig) { c.CallerRate, c.CallerBurst = -1, 1 }, false},
+		{"huge caller rate", func(c *MethodConfig) { c.CallerRate, c.CallerBurst = 1e300, 1 }, false},
+		{"NaN caller rate", func(c *MethodConfig) { c.CallerRate, c.CallerBurst = math.NaN(), 1 }, false},
+		{"infinite caller rate", func(c *MethodConfig) { c.CallerRate, c.CallerBurst = math.Inf(1), 1 }, false},
+		{"zero caller burst", func(c *MethodConfig) { c.CallerRate = 1 }, false},
+		{"NaN trip ratio", func(c *MethodConfig) { c.BreakerTripRatio = math.NaN() }, false},
+	} {
+		c := valid
+		testcase.mod(&c)
+		err := c.Validate()
+		if want, have := testcase.valid, err == nil; want != have {
+			t.Errorf("%s: want valid %v, have error %v", testcase.name, want, err)
+		}
+		if err == nil { // the rate limiters mustn't panic
+			nop := func(context.Context, interface{}) (interface{}, error) { return nil, nil }
+			c.RateLimiter(1)(nop)(context.Background(), nil)
+		}
+	}
+}
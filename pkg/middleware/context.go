@@ -0,0 +1,37 @@
+package middleware
+
+import "golang.org/x/net/context"
+
+type contextKey int
+
+const (
+	callerKey contextKey = iota
+	requestIDKey
+)
+
+// WithCaller returns a context carrying the caller key, which identifies the
+// caller for the purposes of rate limiting. Transports should set it from e.g.
+// an API key or the client IP before invoking an endpoint.
+func WithCaller(ctx context.Context, caller string) context.Context {
+	return context.WithValue(ctx, callerKey, caller)
+}
+
+// CallerFromContext returns the caller key set by WithCaller, or the empty
+// string if none was set.
+func CallerFromContext(ctx context.Context) string {
+	caller, _ := ctx.Value(callerKey).(string)
+	return caller
+}
+
+// WithRequestID returns a context carrying the request ID, which identifies a
+// single request in logs and error responses.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestIDFromContext returns the request ID set by WithRequestID, or the
+// empty string if none was set.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
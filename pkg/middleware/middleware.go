@@ -1,4 +1,4 @@
-package endpoints
+package middleware
 
 import (
 	"fmt"
@@ -10,11 +10,11 @@ import (
 	"golang.org/x/net/context"
 )
 
-// InstrumentingMiddleware returns an endpoint middleware that records
+// Instrumenting returns an endpoint middleware that records
 // the duration of each invocation to the passed histogram. The middleware adds
 // a single field: "success", which is "true" if no error is returned, and
 // "false" otherwise.
-func InstrumentingMiddleware(duration metrics.Histogram) endpoint.Middleware {
+func Instrumenting(duration metrics.Histogram) endpoint.Middleware {
 	return func(next endpoint.Endpoint) endpoint.Endpoint {
 		return func(ctx context.Context, request interface{}) (response interface{}, err error) {
 
@@ -27,9 +27,9 @@ func InstrumentingMiddleware(duration metrics.Histogram) endpoint.Middleware {
 	}
 }
 
-// LoggingMiddleware returns an endpoint middleware that logs the
+// Logging returns an endpoint middleware that logs the
 // duration of each invocation, and the resulting error, if any.
-func LoggingMiddleware(logger log.Logger) endpoint.Middleware {
+func Logging(logger log.Logger) endpoint.Middleware {
 	return func(next endpoint.Endpoint) endpoint.Endpoint {
 		return func(ctx context.Context, request interface{}) (response interface{}, err error) {
 
@@ -0,0 +1,131 @@
+package middleware
+
+import (
+	"container/list"
+	"errors"
+	"sync"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/ratelimit"
+	rl "github.com/juju/ratelimit"
+	"golang.org/x/net/context"
+)
+
+// ErrRateLimited is returned by a Limiter when a caller has exceeded its own
+// rate limit. It's distinct from the global ratelimit.ErrLimited, so transports
+// can tell one caller backing off from the service backing off.
+var ErrRateLimited = errors.New("caller rate limit exceeded")
+
+// ErrBatchTooLarge is returned when a batch request costs more tokens than the
+// rate limiter's bucket can hold, so it could never be admitted.
+var ErrBatchTooLarge = errors.New("batch size exceeds rate limit burst")
+
+// Limiter charges requests against an overall token bucket, and against the
+// caller's own token bucket, either of which may be nil to disable it. Use
+// MethodConfig.Limiter to construct one.
+type Limiter struct {
+	mtx     sync.Mutex
+	bucket  *rl.Bucket
+	callers *bucketCache
+}
+
+// Middleware returns an endpoint middleware that charges each request the
+// number of tokens returned by cost. Requests are admitted only if all of the
+// tokens are available in both buckets; otherwise, none are taken from either.
+// Rejected requests fail with ErrRateLimited if the caller's limit was hit, or
+// ratelimit.ErrLimited if the overall limit was hit, like the
+// ratelimit.NewTokenBucketLimiter.
+func (l *Limiter) Middleware(cost func(request interface{}) int64) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			if err := l.take(CallerFromContext(ctx), cost(request)); err != nil {
+				return nil, err
+			}
+			return next(ctx, request)
+		}
+	}
+}
+
+// take charges n tokens against the caller's bucket and the overall bucket, or
+// against neither. The buckets are only ever charged here, under the mutex, and
+// tokens only accumulate between checking and taking them, so once both checks
+// pass, both takes succeed.
+func (l *Limiter) take(caller string, n int64) error {
+	var tb *rl.Bucket
+	if l.callers != nil {
+		tb = l.callers.get(caller)
+	}
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if err := check(tb, n, ErrRateLimited); err != nil {
+		return err
+	}
+	if err := check(l.bucket, n, ratelimit.ErrLimited); err != nil {
+		return err
+	}
+	for _, b := range []*rl.Bucket{tb, l.bucket} {
+		if b != nil {
+			b.TakeAvailable(n)
+		}
+	}
+	return nil
+}
+
+func check(tb *rl.Bucket, n int64, limited error) error {
+	if tb == nil {
+		return nil
+	}
+	if n > tb.Capacity() {
+		return ErrBatchTooLarge
+	}
+	if tb.Available() < n {
+		return limited
+	}
+	return nil
+}
+
+// Single is the cost of a request for a single operation.
+func Single(interface{}) int64 { return 1 }
+
+// bucketCache is a size-bounded LRU cache of token buckets.
+type bucketCache struct {
+	mtx     sync.Mutex
+	size    int
+	newFunc func() *rl.Bucket
+	order   *list.List // of *bucketEntry, most recently used first
+	entries map[string]*list.Element
+}
+
+type bucketEntry struct {
+	key    string
+	bucket *rl.Bucket
+}
+
+func newBucketCache(size int, newFunc func() *rl.Bucket) *bucketCache {
+	return &bucketCache{
+		size:    size,
+		newFunc: newFunc,
+		order:   list.New(),
+		entries: map[string]*list.Element{},
+	}
+}
+
+func (c *bucketCache) get(key string) *rl.Bucket {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if e, ok := c.entries[key]; ok {
+		c.order.MoveToFront(e)
+		return e.Value.(*bucketEntry).bucket
+	}
+
+	entry := &bucketEntry{key: key, bucket: c.newFunc()}
+	c.entries[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*bucketEntry).key)
+	}
+	return entry.bucket
+}
@@ -0,0 +1,204 @@
+// Package openapi describes HTTP APIs as OpenAPI 3 documents, with schemas
+// generated from the Go request and response types, and verifies that
+// handlers conform to their documents.
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// Version is the version of the OpenAPI specification that documents follow.
+const Version = "3.0.3"
+
+// Document is an OpenAPI document. Only the parts of the specification that
+// our services need are modelled.
+type Document struct {
+	OpenAPI string              `json:"openapi"`
+	Info    Info                `json:"info"`
+	Paths   map[string]PathItem `json:"paths"`
+}
+
+// Info describes the API as a whole.
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// PathItem collects the operations on a single path, by lowercase method.
+type PathItem map[string]*Operation
+
+// Operation describes a single method on a path.
+type Operation struct {
+	Summary     string              `json:"summary,omitempty"`
+	OperationID string              `json:"operationId,omitempty"`
+	Parameters  []Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]Response `json:"responses"` // by status code, or "default"
+}
+
+// Parameter describes a query string parameter.
+type Parameter struct {
+	Name     string      `json:"name"`
+	In       string      `json:"in"`
+	Required bool        `json:"required,omitempty"`
+	Schema   *Schema     `json:"schema"`
+	Example  interface{} `json:"example,omitempty"`
+}
+
+// RequestBody describes the body of a request, by media type.
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response describes a response, with its body by media type.
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType describes a body of one media type.
+type MediaType struct {
+	Schema  *Schema     `json:"schema"`
+	Example interface{} `json:"example,omitempty"`
+}
+
+// Schema is a JSON schema, as restricted by OpenAPI.
+type Schema struct {
+	Type        string             `json:"type,omitempty"`
+	Format      string             `json:"format,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+
+	// AdditionalProperties is false for objects generated from structs, so
+	// that unknown fields are errors, as they are in our decoders.
+	AdditionalProperties *bool `json:"additionalProperties,omitempty"`
+}
+
+// New returns an empty document describing the API with the given title and
+// version.
+func New(title, version string) *Document {
+	return &Document{
+		OpenAPI: Version,
+		Info:    Info{Title: title, Version: version},
+		Paths:   map[string]PathItem{},
+	}
+}
+
+// Add adds the operation on the given method and path to the document.
+func (d *Document) Add(method, path string, op *Operation) {
+	item, ok := d.Paths[path]
+	if !ok {
+		item = PathItem{}
+		d.Paths[path] = item
+	}
+	item[strings.ToLower(method)] = op
+}
+
+// ServeHTTP serves the document as JSON.
+func (d *Document) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(d)
+}
+
+// JSONBody returns a required request body of the example's type, in each of
+// the given media types, which all share the JSON schema. The example is
+// documented as the JSON body, and used by Verify.
+func JSONBody(example interface{}, mediaTypes ...string) *RequestBody {
+	body := &RequestBody{Required: true, Content: map[string]MediaType{}}
+	schema := SchemaOf(example)
+	for _, mediaType := range append([]string{"application/json"}, mediaTypes...) {
+		body.Content[mediaType] = MediaType{Schema: schema}
+	}
+	body.Content["application/json"] = MediaType{Schema: schema, Example: example}
+	return body
+}
+
+// JSONResponse returns a response with a JSON body of v's type, which is
+// also declared in each of the given media types.
+func JSONResponse(description string, v interface{}, mediaTypes ...string) Response {
+	resp := Response{Description: description, Content: map[string]MediaType{}}
+	schema := SchemaOf(v)
+	for _, mediaType := range append([]string{"application/json"}, mediaTypes...) {
+		resp.Content[mediaType] = MediaType{Schema: schema}
+	}
+	return resp
+}
+
+// QueryParameter returns a query string parameter of the example's type.
+func QueryParameter(name string, required bool, example interface{}) Parameter {
+	return Parameter{Name: name, In: "query", Required: required, Schema: SchemaOf(example), Example: example}
+}
+
+// SchemaOf returns the schema of v's type, as encoded by package json. Struct
+// fields are required, unless they're tagged omitempty.
+func SchemaOf(v interface{}) *Schema {
+	return schemaOf(reflect.TypeOf(v))
+}
+
+func schemaOf(t reflect.Type) *Schema {
+	if t == nil {
+		return &Schema{} // any value
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return schemaOf(t.Elem())
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object"}
+	case reflect.Struct:
+		no := false
+		s := &Schema{Type: "object", Properties: map[string]*Schema{}, AdditionalProperties: &no}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, omitempty, ok := FieldName(f)
+			if !ok {
+				continue
+			}
+			s.Properties[name] = schemaOf(f.Type)
+			if !omitempty {
+				s.Required = append(s.Required, name)
+			}
+		}
+		return s
+	}
+	return &Schema{} // interfaces, and anything else, may be any value
+}
+
+// FieldName returns the name of the struct field as encoded by package json,
+// whether it's tagged omitempty, and whether it's encoded at all.
+func FieldName(f reflect.StructField) (name string, omitempty, ok bool) {
+	tag := f.Tag.Get("json")
+	if tag == "-" || f.PkgPath != "" {
+		return "", false, false
+	}
+	parts := strings.Split(tag, ",")
+	name = parts[0]
+	if name == "" {
+		name = f.Name
+	}
+	for _, option := range parts[1:] {
+		if option == "omitempty" {
+			omitempty = true
+		}
+	}
+	return name, omitempty, true
+}
@@ -0,0 +1,75 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type echoRequest struct {
+	S string `json:"s"`
+	N int    `json:"n,omitempty"`
+}
+
+type echoResponse struct {
+	V   string `json:"v"`
+	Err error  `json:"-"`
+}
+
+func TestSchemaOf(t *testing.T) {
+	buf, err := json.Marshal(SchemaOf(echoRequest{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"type":"object","properties":{"n":{"type":"integer","format":"int64"},"s":{"type":"string"}},"required":["s"],"additionalProperties":false}`
+	if have := string(buf); want != have {
+		t.Errorf("want %s, have %s", want, have)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	d := New("echo", "1")
+	d.Add("POST", "/echo", &Operation{
+		RequestBody: JSONBody(echoRequest{S: "hi"}),
+		Responses:   map[string]Response{"200": JSONResponse("echoed", echoResponse{})},
+	})
+
+	for _, testcase := range []struct {
+		name    string
+		handler http.HandlerFunc
+		want    string // in the error, or empty if it should conform
+	}{
+		{"conforming", func(w http.ResponseWriter, r *http.Request) {
+			var req echoRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			json.NewEncoder(w).Encode(echoResponse{V: req.S})
+		}, ""},
+		{"renamed response field", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"value":"hi"}`))
+		}, `missing required property "v"`},
+		{"wrong response type", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"v":1}`))
+		}, "$.v: want string"},
+		{"undeclared status", func(w http.ResponseWriter, r *http.Request) {
+			http.NotFound(w, r)
+		}, "undeclared status 404"},
+	} {
+		err := Verify(testcase.handler, d)
+		switch {
+		case testcase.want == "" && err != nil:
+			t.Errorf("%s: want no error, have %v", testcase.name, err)
+		case testcase.want != "" && (err == nil || !strings.Contains(err.Error(), testcase.want)):
+			t.Errorf("%s: want error containing %q, have %v", testcase.name, testcase.want, err)
+		}
+	}
+	echo := func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(echoResponse{V: "hi"})
+	}
+	if err := Verify(http.HandlerFunc(echo), d, "/echo"); err != nil {
+		t.Errorf("documented route: want no error, have %v", err)
+	}
+	if want, err := "/shout: not documented", Verify(http.HandlerFunc(echo), d, "/echo", "/shout"); err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("undocumented route: want error containing %q, have %v", want, err)
+	}
+}
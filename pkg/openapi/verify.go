@@ -0,0 +1,177 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Verify checks that the handler conforms to the document. Each operation is
+// invoked with its documented examples, as a JSON body or query string
+// parameters, and must succeed, with a status code and a JSON body that the
+// document declares. Each of the routes, i.e. the paths that the handler
+// serves, must be documented. It returns an error describing every operation
+// that doesn't conform, e.g. because its path isn't handled, or because the
+// handler's request or response types have drifted from the document, and
+// every route that isn't documented. Primarily useful in tests.
+func Verify(h http.Handler, d *Document, routes ...string) error {
+	var problems []string
+	sort.Strings(routes)
+	for _, path := range routes {
+		if _, ok := d.Paths[path]; !ok {
+			problems = append(problems, fmt.Sprintf("%s: not documented", path))
+		}
+	}
+	paths := make([]string, 0, len(d.Paths))
+	for path := range d.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		methods := make([]string, 0, len(d.Paths[path]))
+		for method := range d.Paths[path] {
+			methods = append(methods, method)
+		}
+		sort.Strings(methods)
+		for _, method := range methods {
+			if err := verifyOperation(h, strings.ToUpper(method), path, d.Paths[path][method]); err != nil {
+				problems = append(problems, fmt.Sprintf("%s %s: %v", strings.ToUpper(method), path, err))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("handler doesn't conform to document:\n%s", strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+func verifyOperation(h http.Handler, method, path string, op *Operation) error {
+	query := url.Values{}
+	for _, p := range op.Parameters {
+		if p.In == "query" && p.Example != nil {
+			query.Set(p.Name, fmt.Sprint(p.Example))
+		}
+	}
+	target := path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	var body bytes.Buffer
+	var contentType string
+	if op.RequestBody != nil {
+		mt, ok := op.RequestBody.Content["application/json"]
+		if !ok || mt.Example == nil {
+			return fmt.Errorf("request body has no JSON example")
+		}
+		if err := json.NewEncoder(&body).Encode(mt.Example); err != nil {
+			return err
+		}
+		contentType = "application/json"
+	}
+
+	req := httptest.NewRequest(method, target, &body)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Accept", "application/json")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	resp, ok := op.Responses[strconv.Itoa(rec.Code)]
+	if !ok {
+		resp, ok = op.Responses["default"]
+	}
+	if !ok {
+		return fmt.Errorf("undeclared status %d: %s", rec.Code, strings.TrimSpace(rec.Body.String()))
+	}
+	if rec.Code < 200 || rec.Code > 299 {
+		return fmt.Errorf("example failed with status %d: %s", rec.Code, strings.TrimSpace(rec.Body.String()))
+	}
+	mt, ok := resp.Content["application/json"]
+	if !ok {
+		return nil // no body declared
+	}
+	dec := json.NewDecoder(rec.Body)
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return fmt.Errorf("response body: %v", err)
+	}
+	if err := mt.Schema.Validate(v); err != nil {
+		return fmt.Errorf("response body: %v", err)
+	}
+	return nil
+}
+
+// Validate checks that v, as decoded by package json with UseNumber, conforms
+// to the schema.
+func (s *Schema) Validate(v interface{}) error {
+	return s.validate("$", v)
+}
+
+func (s *Schema) validate(at string, v interface{}) error {
+	switch s.Type {
+	case "":
+		return nil // any value
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%s: want boolean, have %T", at, v)
+		}
+	case "integer":
+		n, ok := v.(json.Number)
+		if !ok {
+			return fmt.Errorf("%s: want integer, have %T", at, v)
+		}
+		if _, err := n.Int64(); err != nil {
+			return fmt.Errorf("%s: want integer, have %s", at, n)
+		}
+	case "number":
+		if _, ok := v.(json.Number); !ok {
+			return fmt.Errorf("%s: want number, have %T", at, v)
+		}
+	case "string":
+		if _, ok := v.(string); !ok {
+			return fmt.Errorf("%s: want string, have %T", at, v)
+		}
+	case "array":
+		a, ok := v.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: want array, have %T", at, v)
+		}
+		for i, item := range a {
+			if err := s.Items.validate(fmt.Sprintf("%s[%d]", at, i), item); err != nil {
+				return err
+			}
+		}
+	case "object":
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: want object, have %T", at, v)
+		}
+		for _, name := range s.Required {
+			if _, ok := m[name]; !ok {
+				return fmt.Errorf("%s: missing required property %q", at, name)
+			}
+		}
+		for name, value := range m {
+			prop, ok := s.Properties[name]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					return fmt.Errorf("%s: unknown property %q", at, name)
+				}
+				continue
+			}
+			if err := prop.validate(at+"."+name, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+// Package shutdown stops a service in phases, so that load balancers stop
+// sending it new requests, and those in flight finish, before it exits.
+package shutdown
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"golang.org/x/net/context"
+
+	"github.com/peterbourgon/go-microservices/pkg/health"
+)
+
+// DrainFunc stops a server accepting new requests, and waits until those in
+// flight are done, or until the context is done. The Shutdown method of an
+// http.Server is a DrainFunc.
+type DrainFunc func(context.Context) error
+
+// Sequence describes how to shut down a service.
+type Sequence struct {
+	// Readiness is failed first, so load balancers stop sending us new
+	// requests.
+	Readiness *health.Flag
+
+	// Delay is how long to keep serving with readiness failing, before
+	// draining.
+	Delay time.Duration
+
+	// Timeout is the deadline for draining in-flight requests.
+	Timeout time.Duration
+
+	// Transports are drained in parallel, by name.
+	Transports map[string]DrainFunc
+
+	// Tracer is closed last, to flush any buffered spans. It may be nil.
+	Tracer io.Closer
+}
+
+// Run shuts down in phases, and returns once the tracer has been flushed.
+func (s Sequence) Run(logger log.Logger) {
+	// First, fail readiness, and keep serving for a while, so load balancers
+	// stop sending us new requests.
+	s.Readiness.Fail()
+	logger.Log("shutdown", "readiness failing", "delay", s.Delay)
+	time.Sleep(s.Delay)
+
+	// Then, stop accepting new connections, and drain in-flight requests,
+	// until they're all done or the deadline passes.
+	logger.Log("shutdown", "draining", "timeout", s.Timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), s.Timeout)
+	defer cancel()
+	var wg sync.WaitGroup
+	for name, drain := range s.Transports {
+		wg.Add(1)
+		go func(name string, drain DrainFunc) {
+			defer wg.Done()
+			logger.Log("shutdown", "drained", "transport", name, "err", drain(ctx))
+		}(name, drain)
+	}
+	wg.Wait()
+
+	// Finally, flush any buffered spans.
+	if s.Tracer != nil {
+		logger.Log("shutdown", "flushing tracer", "err", s.Tracer.Close())
+	}
+	logger.Log("shutdown", "complete")
+}
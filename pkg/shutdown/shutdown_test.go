@@ -0,0 +1,85 @@
+package shutdown
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"golang.org/x/net/context"
+
+	"github.com/peterbourgon/go-microservices/pkg/health"
+)
+
+func TestInFlightRequestFinishes(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.Write([]byte("done"))
+	})}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(ln)
+
+	type result struct {
+		body string
+		err  error
+	}
+	resultc := make(chan result, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String())
+		if err != nil {
+			resultc <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		resultc <- result{string(body), err}
+	}()
+	<-started
+
+	// The signal arrives while the request is in flight.
+	readiness := health.NewFlag(errors.New("shutting down"))
+	draining := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		Sequence{
+			Readiness: readiness,
+			Delay:     10 * time.Millisecond,
+			Timeout:   5 * time.Second,
+			Transports: map[string]DrainFunc{
+				"HTTP": func(ctx context.Context) error {
+					close(draining)
+					return server.Shutdown(ctx)
+				},
+			},
+		}.Run(log.NewNopLogger())
+		close(done)
+	}()
+
+	<-draining
+	if err := readiness.Check(); err == nil {
+		t.Error("readiness: want failing, have ok")
+	}
+	select {
+	case <-done:
+		t.Fatal("shutdown completed with a request in flight")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	if r := <-resultc; r.err != nil || r.body != "done" {
+		t.Errorf("in-flight request: want %q, have %q (%v)", "done", r.body, r.err)
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("shutdown didn't complete after the request finished")
+	}
+}
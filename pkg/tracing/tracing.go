@@ -0,0 +1,183 @@
+// Package tracing constructs the OpenTracing tracer that a service reports its
+// spans to, with the backend chosen by configuration.
+package tracing
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	stdlog "log"
+	"strconv"
+	"strings"
+
+	"github.com/go-kit/kit/log"
+	stdopentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	zipkinot "github.com/openzipkin-contrib/zipkin-go-opentracing"
+	"github.com/openzipkin/zipkin-go"
+	"github.com/openzipkin/zipkin-go/model"
+	zipkinhttp "github.com/openzipkin/zipkin-go/reporter/http"
+	jaeger "github.com/uber/jaeger-client-go"
+	jaegerconfig "github.com/uber/jaeger-client-go/config"
+	"golang.org/x/net/context"
+)
+
+// Backends that spans can be reported to.
+const (
+	// None discards spans. It's the default.
+	None = "none"
+
+	// Zipkin reports spans to a Zipkin collector over HTTP.
+	Zipkin = "zipkin"
+
+	// Jaeger reports spans to a Jaeger agent over UDP.
+	Jaeger = "jaeger"
+
+	// Memory records finished spans in process, where they can be inspected
+	// via the *mocktracer.MockTracer. Every span is recorded, regardless of
+	// the sample rate. Primarily useful in tests.
+	Memory = "memory"
+)
+
+// Default addresses of each backend, used if Config.Addr is empty.
+const (
+	DefaultZipkinAddr = "http://localhost:9411/api/v2/spans"
+	DefaultJaegerAddr = "localhost:6831"
+)
+
+// Config selects and configures the tracer backend.
+type Config struct {
+	Backend     string  // None, Zipkin, Jaeger, or Memory
+	Addr        string  // Zipkin collector URL, or Jaeger agent host:port
+	SampleRate  float64 // fraction of traces to report, from 0 to 1
+	ServiceName string  // name that spans are reported under
+}
+
+// DefaultConfig returns a config that discards spans. If another backend is
+// chosen, every trace is reported, under the given service name.
+func DefaultConfig(serviceName string) Config {
+	return Config{
+		Backend:     None,
+		SampleRate:  1,
+		ServiceName: serviceName,
+	}
+}
+
+// Flags registers flags for each of the config's parameters on fs, with the
+// config's current values as defaults.
+func (c *Config) Flags(fs *flag.FlagSet) {
+	fs.StringVar(&c.Backend, "tracer.backend", c.Backend, "tracer backend: none, zipkin, jaeger, or memory")
+	fs.StringVar(&c.Addr, "tracer.addr", c.Addr, "Zipkin collector URL, or Jaeger agent host:port (empty for the backend's default)")
+	fs.Float64Var(&c.SampleRate, "tracer.sample", c.SampleRate, "fraction of traces to report, from 0 to 1")
+	fs.StringVar(&c.ServiceName, "tracer.service", c.ServiceName, "service name that spans are reported under")
+}
+
+// Validate returns an error if any of the parameters are nonsensical.
+func (c Config) Validate() error {
+	switch c.Backend {
+	case None, Zipkin, Jaeger, Memory:
+	default:
+		return fmt.Errorf("unknown tracer backend %q", c.Backend)
+	}
+	if c.SampleRate < 0 || c.SampleRate > 1 {
+		return fmt.Errorf("sample rate must be from 0 to 1, have %v", c.SampleRate)
+	}
+	if c.Backend != None && strings.TrimSpace(c.ServiceName) == "" {
+		return errors.New("service name must not be empty")
+	}
+	return nil
+}
+
+// New returns a tracer for the configured backend, and a closer that flushes
+// any buffered spans, which should be called on shutdown. Errors reporting
+// spans are logged.
+func New(c Config, logger log.Logger) (stdopentracing.Tracer, io.Closer, error) {
+	if err := c.Validate(); err != nil {
+		return nil, nil, err
+	}
+	switch c.Backend {
+	case Zipkin:
+		return newZipkin(c, logger)
+	case Jaeger:
+		return newJaeger(c, logger)
+	case Memory:
+		return mocktracer.New(), nopCloser{}, nil
+	default:
+		return stdopentracing.NoopTracer{}, nopCloser{}, nil
+	}
+}
+
+func newZipkin(c Config, logger log.Logger) (stdopentracing.Tracer, io.Closer, error) {
+	addr := c.Addr
+	if addr == "" {
+		addr = DefaultZipkinAddr
+	}
+	endpoint, err := zipkin.NewEndpoint(c.ServiceName, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	sampler, err := zipkin.NewBoundarySampler(c.SampleRate, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	reporter := zipkinhttp.NewReporter(addr, zipkinhttp.Logger(stdlog.New(log.NewStdlibAdapter(logger), "", 0)))
+	tracer, err := zipkin.NewTracer(reporter, zipkin.WithLocalEndpoint(endpoint), zipkin.WithSampler(sampler))
+	if err != nil {
+		reporter.Close()
+		return nil, nil, err
+	}
+	return zipkinot.Wrap(tracer), reporter, nil
+}
+
+func newJaeger(c Config, logger log.Logger) (stdopentracing.Tracer, io.Closer, error) {
+	addr := c.Addr
+	if addr == "" {
+		addr = DefaultJaegerAddr
+	}
+	return jaegerconfig.Configuration{
+		ServiceName: c.ServiceName,
+		Sampler: &jaegerconfig.SamplerConfig{
+			Type:  jaeger.SamplerTypeProbabilistic,
+			Param: c.SampleRate,
+		},
+		Reporter: &jaegerconfig.ReporterConfig{
+			LocalAgentHostPort: addr,
+		},
+	}.NewTracer(jaegerconfig.Logger(jaegerLogger{logger}))
+}
+
+// jaegerLogger adapts a go-kit logger to the Jaeger client's logger.
+type jaegerLogger struct{ log.Logger }
+
+func (l jaegerLogger) Error(msg string) {
+	l.Log("tracer", Jaeger, "err", msg)
+}
+
+func (l jaegerLogger) Infof(msg string, args ...interface{}) {
+	l.Log("tracer", Jaeger, "msg", fmt.Sprintf(msg, args...))
+}
+
+// TraceID returns the ID of the trace that the span in the context belongs
+// to, as the backend reports it, so that e.g. log lines may be correlated with
+// traces. It returns the empty string if the context has no span, or the span
+// wasn't started by a tracer returned by New.
+func TraceID(ctx context.Context) string {
+	span := stdopentracing.SpanFromContext(ctx)
+	if span == nil {
+		return ""
+	}
+	switch c := span.Context().(type) {
+	case zipkinot.SpanContext:
+		return model.SpanContext(c).TraceID.String()
+	case jaeger.SpanContext:
+		return c.TraceID().String()
+	case mocktracer.MockSpanContext:
+		return strconv.Itoa(c.TraceID)
+	}
+	return ""
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
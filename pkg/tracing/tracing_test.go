@@ -0,0 +1,118 @@
+package tracing
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"golang.org/x/net/context"
+)
+
+func TestValidate(t *testing.T) {
+	for _, testcase := range []struct {
+		name   string
+		modify func(*Config)
+		want   string // in the error, or empty if valid
+	}{
+		{"default", func(c *Config) {}, ""},
+		{"zipkin", func(c *Config) { c.Backend = Zipkin }, ""},
+		{"unknown backend", func(c *Config) { c.Backend = "xray" }, `unknown tracer backend "xray"`},
+		{"negative sample rate", func(c *Config) { c.SampleRate = -0.1 }, "sample rate must be from 0 to 1"},
+		{"excessive sample rate", func(c *Config) { c.SampleRate = 1.5 }, "sample rate must be from 0 to 1"},
+		{"no service name", func(c *Config) { c.Backend = Jaeger; c.ServiceName = " " }, "service name must not be empty"},
+	} {
+		c := DefaultConfig("test")
+		testcase.modify(&c)
+		err := c.Validate()
+		switch {
+		case testcase.want == "" && err != nil:
+			t.Errorf("%s: want no error, have %v", testcase.name, err)
+		case testcase.want != "" && (err == nil || !strings.Contains(err.Error(), testcase.want)):
+			t.Errorf("%s: want error containing %q, have %v", testcase.name, testcase.want, err)
+		}
+	}
+}
+
+func TestMemory(t *testing.T) {
+	c := DefaultConfig("test")
+	c.Backend = Memory
+	tracer, closer, err := New(c, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closer.Close()
+
+	tracer.StartSpan("Sum").Finish()
+	spans := tracer.(*mocktracer.MockTracer).FinishedSpans()
+	if want, have := 1, len(spans); want != have {
+		t.Fatalf("want %d span, have %d", want, have)
+	}
+	if want, have := "Sum", spans[0].OperationName; want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+}
+
+func TestZipkin(t *testing.T) {
+	received := make(chan []map[string]interface{}, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var spans []map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&spans); err != nil {
+			t.Errorf("collector: %v", err)
+		}
+		received <- spans
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer collector.Close()
+
+	c := DefaultConfig("test")
+	c.Backend, c.Addr = Zipkin, collector.URL
+	tracer, closer, err := New(c, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracer.StartSpan("Sum").Finish()
+	if err := closer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := <-received
+	if want, have := 1, len(spans); want != have {
+		t.Fatalf("want %d span, have %d", want, have)
+	}
+	if want, have := "Sum", spans[0]["name"]; want != have {
+		t.Errorf("name: want %q, have %v", want, have)
+	}
+	endpoint, _ := spans[0]["localEndpoint"].(map[string]interface{})
+	if want, have := "test", endpoint["serviceName"]; want != have {
+		t.Errorf("service name: want %q, have %v", want, have)
+	}
+}
+
+func TestTraceID(t *testing.T) {
+	if want, have := "", TraceID(context.Background()); want != have {
+		t.Errorf("no span: want %q, have %q", want, have)
+	}
+	for _, backend := range []string{Zipkin, Jaeger, Memory} {
+		c := DefaultConfig("test")
+		c.Backend = backend
+		tracer, closer, err := New(c, log.NewNopLogger())
+		if err != nil {
+			t.Fatal(err)
+		}
+		parent := tracer.StartSpan("parent")
+		child := tracer.StartSpan("child", opentracing.ChildOf(parent.Context()))
+		id := TraceID(opentracing.ContextWithSpan(context.Background(), child))
+		if id == "" {
+			t.Errorf("%s: want trace ID, have none", backend)
+		}
+		if want, have := id, TraceID(opentracing.ContextWithSpan(context.Background(), parent)); want != have {
+			t.Errorf("%s: parent and child: want %q, have %q", backend, want, have)
+		}
+		closer.Close()
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/rivo/uniseg"
+)
+
+// CountMode is a unit in which Count measures a string.
+type CountMode string
+
+const (
+	// Bytes counts the bytes of the UTF-8 encoding. It's the default.
+	Bytes CountMode = "bytes"
+
+	// Runes counts Unicode code points.
+	Runes CountMode = "runes"
+
+	// Graphemes counts extended grapheme clusters, i.e. user-perceived
+	// characters, so e.g. a letter with combining marks, a flag, or an emoji
+	// ZWJ sequence counts as one.
+	Graphemes CountMode = "graphemes"
+
+	// Words counts words, as delimited by the Unicode word boundary rules of
+	// UAX #29, that contain at least one letter or number. The rules don't
+	// use a dictionary, so each Han ideograph counts as a word of its own.
+	Words CountMode = "words"
+
+	// Lines counts lines, as ended by mandatory line breaks, i.e. LF, CR,
+	// CRLF, NEL, VT, FF, and the line and paragraph separators. A final line
+	// without a break counts, too.
+	Lines CountMode = "lines"
+)
+
+// UnknownModeError is returned by Count for a mode other than those above.
+type UnknownModeError struct {
+	Mode CountMode
+}
+
+func (e UnknownModeError) Error() string {
+	return fmt.Sprintf("%v %q: want bytes, runes, graphemes, words, or lines", ErrUnknownMode, string(e.Mode))
+}
+
+// Counts is the breakdown of a string's size in each of the count modes.
+type Counts struct {
+	Bytes     int `json:"bytes"`
+	Runes     int `json:"runes"`
+	Graphemes int `json:"graphemes"`
+	Words     int `json:"words"`
+	Lines     int `json:"lines"`
+}
+
+// count measures s in the given mode, which must be known. The empty mode is
+// Bytes.
+func count(s string, mode CountMode) int {
+	switch mode {
+	case Runes:
+		return utf8.RuneCountInString(s)
+	case Graphemes:
+		return uniseg.GraphemeClusterCount(s)
+	case Words:
+		return countWords(s)
+	case Lines:
+		return countLines(s)
+	}
+	return len(s)
+}
+
+// countAll measures s in each of the count modes.
+func countAll(s string) Counts {
+	return Counts{
+		Bytes:     count(s, Bytes),
+		Runes:     count(s, Runes),
+		Graphemes: count(s, Graphemes),
+		Words:     count(s, Words),
+		Lines:     count(s, Lines),
+	}
+}
+
+// In returns the count in the given mode, which must be known. The empty mode
+// is Bytes.
+func (c Counts) In(mode CountMode) (int, error) {
+	switch mode {
+	case Bytes, "":
+		return c.Bytes, nil
+	case Runes:
+		return c.Runes, nil
+	case Graphemes:
+		return c.Graphemes, nil
+	case Words:
+		return c.Words, nil
+	case Lines:
+		return c.Lines, nil
+	}
+	return 0, UnknownModeError{Mode: mode}
+}
+
+func countWords(s string) (n int) {
+	state := -1
+	for s != "" {
+		var word string
+		word, s, state = uniseg.FirstWordInString(s, state)
+		for _, r := range word {
+			if unicode.IsLetter(r) || unicode.IsNumber(r) {
+				n++
+				break
+			}
+		}
+	}
+	return n
+}
+
+func countLines(s string) (n int) {
+	state := -1
+	for s != "" {
+		var mustBreak bool
+		_, s, mustBreak, state = uniseg.FirstLineSegmentInString(s, state)
+		if mustBreak { // including at the end of the text
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/go-kit/kit/circuitbreaker"
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/tracing/opentracing"
+	stdopentracing "github.com/opentracing/opentracing-go"
+	"github.com/sony/gobreaker"
+	"golang.org/x/net/context"
+
+	"github.com/peterbourgon/go-microservices/pkg/middleware"
+)
+
+// Config collects the tunable parameters of the endpoint middlewares wired in
+// by New, per method. They're the same as addsvc's.
+type Config struct {
+	Uppercase middleware.MethodConfig
+	Lowercase middleware.MethodConfig
+	Titlecase middleware.MethodConfig
+	Count     middleware.MethodConfig
+	Normalize middleware.MethodConfig
+
+	// MaxCallers bounds the number of callers tracked by each method's
+	// per-caller rate limiter. It's only used if a CallerRate is set.
+	MaxCallers int
+}
+
+// DefaultConfig returns the Config used by the service unless told otherwise.
+func DefaultConfig() Config {
+	return Config{
+		Uppercase:  middleware.MethodConfig{Rate: 100, Burst: 100},
+		Lowercase:  middleware.MethodConfig{Rate: 100, Burst: 100},
+		Titlecase:  middleware.MethodConfig{Rate: 100, Burst: 100},
+		Count:      middleware.MethodConfig{Rate: 100, Burst: 100},
+		Normalize:  middleware.MethodConfig{Rate: 100, Burst: 100},
+		MaxCallers: 10000,
+	}
+}
+
+// Validate returns an error if any of the parameters are nonsensical.
+func (c Config) Validate() error {
+	if err := c.Uppercase.Validate(); err != nil {
+		return fmt.Errorf("Uppercase: %v", err)
+	}
+	if err := c.Lowercase.Validate(); err != nil {
+		return fmt.Errorf("Lowercase: %v", err)
+	}
+	if err := c.Titlecase.Validate(); err != nil {
+		return fmt.Errorf("Titlecase: %v", err)
+	}
+	if err := c.Count.Validate(); err != nil {
+		return fmt.Errorf("Count: %v", err)
+	}
+	if err := c.Normalize.Validate(); err != nil {
+		return fmt.Errorf("Normalize: %v", err)
+	}
+	perCaller := c.Uppercase.CallerRate > 0 || c.Lowercase.CallerRate > 0 || c.Titlecase.CallerRate > 0 || c.Count.CallerRate > 0 || c.Normalize.CallerRate > 0
+	if perCaller && c.MaxCallers < 1 {
+		return fmt.Errorf("max callers must be at least 1, have %d", c.MaxCallers)
+	}
+	return nil
+}
+
+// New returns an Endpoints that wraps the provided service, and wires in all
+// of the expected endpoint middlewares via the various parameters, in the same
+// order as addsvc. The config is assumed to be valid; see Config.Validate.
+func New(svc StringService, cfg Config, logger log.Logger, duration metrics.Histogram, trace stdopentracing.Tracer) Endpoints {
+	// The rate limiters are applied outside of the circuit breakers, so that
+	// rejected requests don't count as failures, and one caller exceeding its
+	// rate limit can't trip the breaker for everyone.
+	var (
+		uppercaseBreaker = cfg.Uppercase.Breaker("Uppercase")
+		lowercaseBreaker = cfg.Lowercase.Breaker("Lowercase")
+		titlecaseBreaker = cfg.Titlecase.Breaker("Titlecase")
+		countBreaker     = cfg.Count.Breaker("Count")
+		normalizeBreaker = cfg.Normalize.Breaker("Normalize")
+	)
+	var uppercaseEndpoint endpoint.Endpoint
+	{
+		uppercaseEndpoint = makeUppercaseEndpoint(svc)
+		uppercaseEndpoint = circuitbreaker.Gobreaker(uppercaseBreaker)(uppercaseEndpoint)
+		uppercaseEndpoint = cfg.Uppercase.RateLimiter(cfg.MaxCallers)(uppercaseEndpoint)
+		uppercaseEndpoint = opentracing.TraceServer(trace, "Uppercase")(uppercaseEndpoint)
+		uppercaseEndpoint = middleware.Logging(log.NewContext(logger).With("method", "Uppercase"))(uppercaseEndpoint)
+		uppercaseEndpoint = middleware.Instrumenting(duration.With("method", "Uppercase"))(uppercaseEndpoint)
+	}
+	var lowercaseEndpoint endpoint.Endpoint
+	{
+		lowercaseEndpoint = makeLowercaseEndpoint(svc)
+		lowercaseEndpoint = circuitbreaker.Gobreaker(lowercaseBreaker)(lowercaseEndpoint)
+		lowercaseEndpoint = cfg.Lowercase.RateLimiter(cfg.MaxCallers)(lowercaseEndpoint)
+		lowercaseEndpoint = opentracing.TraceServer(trace, "Lowercase")(lowercaseEndpoint)
+		lowercaseEndpoint = middleware.Logging(log.NewContext(logger).With("method", "Lowercase"))(lowercaseEndpoint)
+		lowercaseEndpoint = middleware.Instrumenting(duration.With("method", "Lowercase"))(lowercaseEndpoint)
+	}
+	var titlecaseEndpoint endpoint.Endpoint
+	{
+		titlecaseEndpoint = makeTitlecaseEndpoint(svc)
+		titlecaseEndpoint = circuitbreaker.Gobreaker(titlecaseBreaker)(titlecaseEndpoint)
+		titlecaseEndpoint = cfg.Titlecase.RateLimiter(cfg.MaxCallers)(titlecaseEndpoint)
+		titlecaseEndpoint = opentracing.TraceServer(trace, "Titlecase")(titlecaseEndpoint)
+		titlecaseEndpoint = middleware.Logging(log.NewContext(logger).With("method", "Titlecase"))(titlecaseEndpoint)
+		titlecaseEndpoint = middleware.Instrumenting(duration.With("method", "Titlecase"))(titlecaseEndpoint)
+	}
+	var countEndpoint endpoint.Endpoint
+	{
+		countEndpoint = makeCountEndpoint(svc)
+		countEndpoint = circuitbreaker.Gobreaker(countBreaker)(countEndpoint)
+		countEndpoint = cfg.Count.RateLimiter(cfg.MaxCallers)(countEndpoint)
+		countEndpoint = opentracing.TraceServer(trace, "Count")(countEndpoint)
+		countEndpoint = middleware.Logging(log.NewContext(logger).With("method", "Count"))(countEndpoint)
+		countEndpoint = middleware.Instrumenting(duration.With("method", "Count"))(countEndpoint)
+	}
+	var normalizeEndpoint endpoint.Endpoint
+	{
+		normalizeEndpoint = makeNormalizeEndpoint(svc)
+		normalizeEndpoint = circuitbreaker.Gobreaker(normalizeBreaker)(normalizeEndpoint)
+		normalizeEndpoint = cfg.Normalize.RateLimiter(cfg.MaxCallers)(normalizeEndpoint)
+		normalizeEndpoint = opentracing.TraceServer(trace, "Normalize")(normalizeEndpoint)
+		normalizeEndpoint = middleware.Logging(log.NewContext(logger).With("method", "Normalize"))(normalizeEndpoint)
+		normalizeEndpoint = middleware.Instrumenting(duration.With("method", "Normalize"))(normalizeEndpoint)
+	}
+	return Endpoints{
+		UppercaseEndpoint: uppercaseEndpoint,
+		LowercaseEndpoint: lowercaseEndpoint,
+		TitlecaseEndpoint: titlecaseEndpoint,
+		CountEndpoint:     countEndpoint,
+		NormalizeEndpoint: normalizeEndpoint,
+		Breakers: map[string]*gobreaker.CircuitBreaker{
+			"Uppercase": uppercaseBreaker,
+			"Lowercase": lowercaseBreaker,
+			"Titlecase": titlecaseBreaker,
+			"Count":     countBreaker,
+			"Normalize": normalizeBreaker,
+		},
+	}
+}
+
+// Endpoints collects all of the endpoints that compose a string service.
+type Endpoints struct {
+	UppercaseEndpoint endpoint.Endpoint
+	LowercaseEndpoint endpoint.Endpoint
+	TitlecaseEndpoint endpoint.Endpoint
+	CountEndpoint     endpoint.Endpoint
+	NormalizeEndpoint endpoint.Endpoint
+
+	// Breakers are the circuit breakers wired in by New, by method name, so
+	// that their state may be reported e.g. in health checks.
+	Breakers map[string]*gobreaker.CircuitBreaker
+}
+
+func makeUppercaseEndpoint(svc StringService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(caseRequest)
+		v, err := svc.Uppercase(ctx, req.S, req.tag)
+		if err != nil {
+			return caseResponse{v, err.Error()}, nil
+		}
+		return caseResponse{v, ""}, nil
+	}
+}
+
+func makeLowercaseEndpoint(svc StringService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(caseRequest)
+		v, err := svc.Lowercase(ctx, req.S, req.tag)
+		if err != nil {
+			return caseResponse{v, err.Error()}, nil
+		}
+		return caseResponse{v, ""}, nil
+	}
+}
+
+func makeTitlecaseEndpoint(svc StringService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(caseRequest)
+		v, err := svc.Titlecase(ctx, req.S, req.tag)
+		if err != nil {
+			return caseResponse{v, err.Error()}, nil
+		}
+		return caseResponse{v, ""}, nil
+	}
+}
+
+func makeCountEndpoint(svc StringService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(countRequest)
+		v, counts, err := svc.Count(ctx, req.S, req.Mode, req.Breakdown)
+		if err != nil {
+			return countResponse{V: v, Err: err.Error()}, nil
+		}
+		return countResponse{V: v, Breakdown: counts}, nil
+	}
+}
+
+func makeNormalizeEndpoint(svc StringService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(normalizeRequest)
+		v, err := svc.Normalize(ctx, req.S, NormalizeOptions{
+			StripControl: req.StripControl,
+			Form:         req.Form,
+			Trim:         req.Trim,
+		})
+		if err != nil {
+			return normalizeResponse{v, err.Error()}, nil
+		}
+		return normalizeResponse{v, ""}, nil
+	}
+}
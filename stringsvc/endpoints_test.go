@@ -0,0 +1,186 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics/discard"
+	opentracing "github.com/opentracing/opentracing-go"
+
+	"github.com/peterbourgon/go-microservices/pkg/health"
+	"github.com/peterbourgon/go-microservices/pkg/httpcontext"
+)
+
+func TestRateLimits(t *testing.T) {
+	config := DefaultConfig()
+	config.Uppercase.Rate, config.Uppercase.Burst = 0.001, 1
+	config.Count.CallerRate, config.Count.CallerBurst = 0.001, 1
+	if err := config.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	eps := New(stringService{}, config, log.NewNopLogger(), discard.NewHistogram(), opentracing.GlobalTracer())
+	mux := makeServeMux(eps, inputLimits{}, nil, log.NewNopLogger(), opentracing.GlobalTracer())
+
+	for _, testcase := range []struct {
+		path, apiKey string
+		want         int
+	}{
+		{"/uppercase", "", http.StatusOK},
+		{"/uppercase", "", http.StatusTooManyRequests}, // the burst is spent
+		{"/count", "alice", http.StatusOK},
+		{"/count", "alice", http.StatusTooManyRequests}, // alice's burst is spent
+		{"/count", "bob", http.StatusOK},                // but not bob's
+	} {
+		req := httptest.NewRequest("POST", testcase.path, strings.NewReader(`{"s":"foo"}`))
+		if testcase.apiKey != "" {
+			req.Header.Set(httpcontext.APIKeyHeader, testcase.apiKey)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		if want, have := testcase.want, rec.Code; want != have {
+			t.Errorf("%s as %q: want %d, have %d: %s", testcase.path, testcase.apiKey, want, have, strings.TrimSpace(rec.Body.String()))
+		}
+	}
+
+	// Rate limited requests are rejected before they reach the circuit
+	// breaker, so however many there are, the service stays ready. The
+	// breaker would trip after more than 5 consecutive failures.
+	for i := 0; i < 10; i++ {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("POST", "/uppercase", strings.NewReader(`{"s":"foo"}`)))
+		if want, have := http.StatusTooManyRequests, rec.Code; want != have {
+			t.Fatalf("want %d, have %d", want, have)
+		}
+	}
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/readyz", nil))
+	if want, have := http.StatusOK, rec.Code; want != have {
+		t.Errorf("/readyz: want %d, have %d: %s", want, have, strings.TrimSpace(rec.Body.String()))
+	}
+}
+
+func TestCountErrors(t *testing.T) {
+	eps := New(stringService{maxCountSize: 8}, DefaultConfig(), log.NewNopLogger(), discard.NewHistogram(), opentracing.GlobalTracer())
+	mux := makeServeMux(eps, inputLimits{Count: 8}, nil, log.NewNopLogger(), opentracing.GlobalTracer())
+
+	for _, testcase := range []struct {
+		body string
+		code int
+		want string
+	}{
+		{`{"s":"foo"}`, http.StatusOK, `{"v":3}`},
+		{`{"s":"e\u0301\u00e9","mode":"graphemes"}`, http.StatusOK, `{"v":2}`},
+		{`{"s":"e\u0301\u00e9","mode":"graphemes","breakdown":true}`, http.StatusOK, `{"v":2,"breakdown":{"bytes":5,"runes":3,"graphemes":2,"words":1,"lines":1}}`},
+		{`{"s":"foo","mode":"chars"}`, http.StatusOK, `{"v":0,"err":"unknown count mode \"chars\": want bytes, runes, graphemes, words, or lines"}`},
+		{`{"s":"123456789"}`, http.StatusOK, `{"v":0,"err":"input exceeds maximum size: 9 bytes, limit 8"}`},
+		{"{\"s\":\"\xff\"}", http.StatusBadRequest, `{"err":"invalid UTF-8"}`},
+		{`{"s":"` + strings.Repeat(`\u0000`, 9) + `"}`, http.StatusOK, `{"v":0,"err":"input exceeds maximum size: 9 bytes, limit 8"}`}, // escaped, but not too large to read
+		{`{"s":"` + strings.Repeat("a", 2000) + `"}`, http.StatusRequestEntityTooLarge, `{"err":"request body too large"}`},
+	} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("POST", "/count", strings.NewReader(testcase.body)))
+		if want, have := testcase.code, rec.Code; want != have {
+			t.Errorf("%q: want %d, have %d", testcase.body, want, have)
+		}
+		if want, have := testcase.want, strings.TrimSpace(rec.Body.String()); want != have {
+			t.Errorf("%q: want %s, have %s", testcase.body, want, have)
+		}
+		if rec.Header().Get(httpcontext.RequestIDHeader) == "" { // even on errors
+			t.Errorf("%q: want a %s header, have none", testcase.body, httpcontext.RequestIDHeader)
+		}
+	}
+}
+
+func TestCaseLanguages(t *testing.T) {
+	eps := New(stringService{}, DefaultConfig(), log.NewNopLogger(), discard.NewHistogram(), opentracing.GlobalTracer())
+	mux := makeServeMux(eps, inputLimits{}, nil, log.NewNopLogger(), opentracing.GlobalTracer())
+
+	for _, testcase := range []struct {
+		path string
+		body string
+		code int
+		want string
+	}{
+		{"/uppercase", `{"s":"istanbul"}`, http.StatusOK, `{"v":"ISTANBUL"}`},
+		{"/uppercase", `{"s":"istanbul","lang":"tr"}`, http.StatusOK, `{"v":"İSTANBUL"}`},
+		{"/uppercase", `{"s":"straße","lang":"de-CH"}`, http.StatusOK, `{"v":"STRASSE"}`},
+		{"/lowercase", `{"s":"ISPARTA","lang":"tr-TR"}`, http.StatusOK, `{"v":"ısparta"}`},
+		{"/titlecase", `{"s":"ijsland","lang":"nl"}`, http.StatusOK, `{"v":"IJsland"}`},
+		{"/lowercase", `{"s":"","lang":"tr"}`, http.StatusOK, `{"v":"","err":"empty string"}`},
+		{"/uppercase", `{"s":"foo","lang":"!!"}`, http.StatusBadRequest, `{"err":"invalid language tag \"!!\": language: tag is not well-formed"}`},
+		{"/lowercase", `{"s":"foo","lang":"en-"}`, http.StatusBadRequest, `{"err":"invalid language tag \"en-\": language: tag is not well-formed"}`},
+		{"/titlecase", `{"s":"foo","lang":"zz"}`, http.StatusOK, `{"v":"Foo"}`},                // unknown, so undetermined
+		{"/uppercase", `{"s":"istanbul","lang":"tr-Qzzz"}`, http.StatusOK, `{"v":"İSTANBUL"}`}, // unknown script dropped
+	} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("POST", testcase.path, strings.NewReader(testcase.body)))
+		if want, have := testcase.code, rec.Code; want != have {
+			t.Errorf("%s %s: want %d, have %d", testcase.path, testcase.body, want, have)
+		}
+		if want, have := testcase.want, strings.TrimSpace(rec.Body.String()); want != have {
+			t.Errorf("%s %s: want %s, have %s", testcase.path, testcase.body, want, have)
+		}
+	}
+}
+
+func TestNormalizeRequests(t *testing.T) {
+	eps := New(stringService{maxNormalizeSize: 8}, DefaultConfig(), log.NewNopLogger(), discard.NewHistogram(), opentracing.GlobalTracer())
+	mux := makeServeMux(eps, inputLimits{Normalize: 8}, nil, log.NewNopLogger(), opentracing.GlobalTracer())
+
+	for _, testcase := range []struct {
+		body string
+		code int
+		want string
+	}{
+		{`{"s":"Cafe\u0301"}`, http.StatusOK, `{"v":"Café"}`},
+		{`{"s":" \u0000\uff21\u0000 ","form":"NFKC","strip_control":true,"trim":true}`, http.StatusOK, `{"v":"A"}`},
+		{`{"s":"foo","form":"nfc"}`, http.StatusOK, `{"v":"","err":"unknown normal form \"nfc\": want NFC, NFD, NFKC, or NFKD"}`},
+		{"{\"s\":\"\xff\"}", http.StatusBadRequest, `{"err":"invalid UTF-8"}`},
+		{`{"s":"123456789"}`, http.StatusOK, `{"v":"","err":"input exceeds maximum size: 9 bytes, limit 8"}`},
+		{`{"s":"` + strings.Repeat("a", 2000) + `"}`, http.StatusRequestEntityTooLarge, `{"err":"request body too large"}`},
+	} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("POST", "/normalize", strings.NewReader(testcase.body)))
+		if want, have := testcase.code, rec.Code; want != have {
+			t.Errorf("%q: want %d, have %d", testcase.body, want, have)
+		}
+		if want, have := testcase.want, strings.TrimSpace(rec.Body.String()); want != have {
+			t.Errorf("%q: want %s, have %s", testcase.body, want, have)
+		}
+	}
+}
+
+func TestHealth(t *testing.T) {
+	for _, testcase := range []struct {
+		name            string
+		setup           func(Endpoints, *health.Flag)
+		healthz, readyz int
+	}{
+		{"serving", func(Endpoints, *health.Flag) {}, http.StatusOK, http.StatusOK},
+		{"breaker open", func(eps Endpoints, _ *health.Flag) {
+			for i := 0; i < 6; i++ { // trips after more than 5 consecutive failures
+				eps.Breakers["Count"].Execute(func() (interface{}, error) { return nil, errors.New("fail") })
+			}
+		}, http.StatusOK, http.StatusServiceUnavailable},
+		{"shutting down", func(_ Endpoints, shutdown *health.Flag) {
+			shutdown.Fail()
+		}, http.StatusOK, http.StatusServiceUnavailable},
+	} {
+		eps := New(stringService{}, DefaultConfig(), log.NewNopLogger(), discard.NewHistogram(), opentracing.GlobalTracer())
+		shutdown := health.NewFlag(errors.New("shutting down"))
+		mux := makeServeMux(eps, inputLimits{}, shutdown, log.NewNopLogger(), opentracing.GlobalTracer())
+		testcase.setup(eps, shutdown)
+
+		for path, want := range map[string]int{"/healthz": testcase.healthz, "/readyz": testcase.readyz} {
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+			if have := rec.Code; want != have {
+				t.Errorf("%s: %s: want %d, have %d", testcase.name, path, want, have)
+			}
+		}
+	}
+}
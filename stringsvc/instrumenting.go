@@ -5,6 +5,8 @@ import (
 	"time"
 
 	"github.com/go-kit/kit/metrics"
+	"golang.org/x/net/context"
+	"golang.org/x/text/language"
 )
 
 type instrumentingMiddleware struct {
@@ -14,23 +16,58 @@ type instrumentingMiddleware struct {
 	next           StringService
 }
 
-func (mw instrumentingMiddleware) Uppercase(s string) (output string, err error) {
+func (mw instrumentingMiddleware) Uppercase(ctx context.Context, s string, lang language.Tag) (output string, err error) {
 	defer func(begin time.Time) {
-		lvs := []string{"method", "uppercase", "error", fmt.Sprint(err == nil)}
+		lvs := []string{"method", "uppercase", "error", fmt.Sprint(err != nil)}
 		mw.requestCount.With(lvs...).Add(1)
 		mw.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
 	}(time.Now())
 
-	return mw.next.Uppercase(s)
+	return mw.next.Uppercase(ctx, s, lang)
 }
 
-func (mw instrumentingMiddleware) Count(s string) (n int) {
+func (mw instrumentingMiddleware) Lowercase(ctx context.Context, s string, lang language.Tag) (output string, err error) {
 	defer func(begin time.Time) {
-		lvs := []string{"method", "count", "error", "false"}
+		lvs := []string{"method", "lowercase", "error", fmt.Sprint(err != nil)}
 		mw.requestCount.With(lvs...).Add(1)
 		mw.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
-		mw.countResult.Observe(float64(n))
 	}(time.Now())
 
-	return mw.next.Count(s)
+	return mw.next.Lowercase(ctx, s, lang)
+}
+
+func (mw instrumentingMiddleware) Titlecase(ctx context.Context, s string, lang language.Tag) (output string, err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "titlecase", "error", fmt.Sprint(err != nil)}
+		mw.requestCount.With(lvs...).Add(1)
+		mw.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return mw.next.Titlecase(ctx, s, lang)
+}
+
+// Count records the result only if it succeeds, so that rejected inputs don't
+// skew the distribution. It's recorded in bytes, whatever the mode, so that
+// the distribution has a single unit.
+func (mw instrumentingMiddleware) Count(ctx context.Context, s string, mode CountMode, breakdown bool) (n int, counts *Counts, err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "count", "error", fmt.Sprint(err != nil)}
+		mw.requestCount.With(lvs...).Add(1)
+		mw.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+		if err == nil {
+			mw.countResult.Observe(float64(len(s)))
+		}
+	}(time.Now())
+
+	return mw.next.Count(ctx, s, mode, breakdown)
+}
+
+func (mw instrumentingMiddleware) Normalize(ctx context.Context, s string, opts NormalizeOptions) (output string, err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "normalize", "error", fmt.Sprint(err != nil)}
+		mw.requestCount.With(lvs...).Add(1)
+		mw.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return mw.next.Normalize(ctx, s, opts)
 }
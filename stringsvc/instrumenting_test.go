@@ -0,0 +1,64 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/discard"
+	"golang.org/x/net/context"
+	"golang.org/x/text/language"
+)
+
+func TestInstrumentingLabels(t *testing.T) {
+	var counted []string
+	mw := instrumentingMiddleware{
+		requestCount:   labelRecorder{record: &counted},
+		requestLatency: discard.NewHistogram(),
+		countResult:    discard.NewHistogram(),
+		next:           stringService{maxCountSize: 3},
+	}
+	mw.Uppercase(context.Background(), "foo", language.Und)
+	mw.Uppercase(context.Background(), "", language.Und)
+	mw.Lowercase(context.Background(), "FOO", language.Und)
+	mw.Titlecase(context.Background(), "foo", language.Und)
+	mw.Count(context.Background(), "foo", Bytes, false)
+	mw.Count(context.Background(), "food", Bytes, false)
+	mw.Normalize(context.Background(), "foo", NormalizeOptions{Form: "NFX"})
+
+	want := []string{
+		"method=uppercase error=false",
+		"method=uppercase error=true",
+		"method=lowercase error=false",
+		"method=titlecase error=false",
+		"method=count error=false",
+		"method=count error=true",
+		"method=normalize error=true",
+	}
+	if len(counted) != len(want) {
+		t.Fatalf("want %q, have %q", want, counted)
+	}
+	for i := range want {
+		if want[i] != counted[i] {
+			t.Errorf("%d: want %q, have %q", i, want[i], counted[i])
+		}
+	}
+}
+
+// labelRecorder is a counter that records the label values of each Add.
+type labelRecorder struct {
+	lvs    []string
+	record *[]string
+}
+
+func (c labelRecorder) With(labelValues ...string) metrics.Counter {
+	return labelRecorder{lvs: append(c.lvs, labelValues...), record: c.record}
+}
+
+func (c labelRecorder) Add(float64) {
+	var pairs []string
+	for i := 0; i+1 < len(c.lvs); i += 2 {
+		pairs = append(pairs, c.lvs[i]+"="+c.lvs[i+1])
+	}
+	*c.record = append(*c.record, strings.Join(pairs, " "))
+}
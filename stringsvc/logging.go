@@ -4,6 +4,11 @@ import (
 	"time"
 
 	"github.com/go-kit/kit/log"
+	"golang.org/x/net/context"
+	"golang.org/x/text/language"
+
+	"github.com/peterbourgon/go-microservices/pkg/middleware"
+	"github.com/peterbourgon/go-microservices/pkg/tracing"
 )
 
 type loggingMiddleware struct {
@@ -11,31 +16,95 @@ type loggingMiddleware struct {
 	next   StringService
 }
 
-func (mw loggingMiddleware) Uppercase(s string) (output string, err error) {
+func (mw loggingMiddleware) Uppercase(ctx context.Context, s string, lang language.Tag) (output string, err error) {
 	defer func(begin time.Time) {
 		mw.logger.Log(
 			"method", "uppercase",
+			"trace_id", tracing.TraceID(ctx),
+			"request_id", middleware.RequestIDFromContext(ctx),
+			"input", s,
+			"lang", lang,
+			"output", output,
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+
+	output, err = mw.next.Uppercase(ctx, s, lang)
+	return
+}
+
+func (mw loggingMiddleware) Lowercase(ctx context.Context, s string, lang language.Tag) (output string, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "lowercase",
+			"trace_id", tracing.TraceID(ctx),
+			"request_id", middleware.RequestIDFromContext(ctx),
+			"input", s,
+			"lang", lang,
+			"output", output,
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+
+	output, err = mw.next.Lowercase(ctx, s, lang)
+	return
+}
+
+func (mw loggingMiddleware) Titlecase(ctx context.Context, s string, lang language.Tag) (output string, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "titlecase",
+			"trace_id", tracing.TraceID(ctx),
+			"request_id", middleware.RequestIDFromContext(ctx),
 			"input", s,
+			"lang", lang,
 			"output", output,
 			"err", err,
 			"took", time.Since(begin),
 		)
 	}(time.Now())
 
-	output, err = mw.next.Uppercase(s)
+	output, err = mw.next.Titlecase(ctx, s, lang)
 	return
 }
 
-func (mw loggingMiddleware) Count(s string) (n int) {
+func (mw loggingMiddleware) Count(ctx context.Context, s string, mode CountMode, breakdown bool) (n int, counts *Counts, err error) {
 	defer func(begin time.Time) {
 		mw.logger.Log(
 			"method", "count",
+			"trace_id", tracing.TraceID(ctx),
+			"request_id", middleware.RequestIDFromContext(ctx),
 			"input", s,
+			"mode", mode,
+			"breakdown", breakdown,
 			"n", n,
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+
+	n, counts, err = mw.next.Count(ctx, s, mode, breakdown)
+	return
+}
+
+func (mw loggingMiddleware) Normalize(ctx context.Context, s string, opts NormalizeOptions) (output string, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "normalize",
+			"trace_id", tracing.TraceID(ctx),
+			"request_id", middleware.RequestIDFromContext(ctx),
+			"input", s,
+			"form", opts.Form,
+			"strip_control", opts.StripControl,
+			"trim", opts.Trim,
+			"output", output,
+			"err", err,
 			"took", time.Since(begin),
 		)
 	}(time.Now())
 
-	n = mw.next.Count(s)
+	output, err = mw.next.Normalize(ctx, s, opts)
 	return
 }
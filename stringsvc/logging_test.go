@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics/discard"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+
+	"github.com/peterbourgon/go-microservices/pkg/httpcontext"
+)
+
+func TestLoggingContext(t *testing.T) {
+	var buf bytes.Buffer
+	trace := mocktracer.New()
+	svc := loggingMiddleware{log.NewLogfmtLogger(&buf), stringService{}}
+	eps := New(svc, DefaultConfig(), log.NewNopLogger(), discard.NewHistogram(), trace)
+	mux := makeServeMux(eps, inputLimits{}, nil, log.NewNopLogger(), trace)
+
+	caller := trace.StartSpan("caller")
+	req := httptest.NewRequest("POST", "/uppercase", strings.NewReader(`{"s":"foo"}`))
+	req.Header.Set(httpcontext.RequestIDHeader, "abc123")
+	if err := trace.Inject(caller.Context(), opentracing.TextMap, opentracing.HTTPHeadersCarrier(req.Header)); err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	if want, have := "abc123", rec.Header().Get(httpcontext.RequestIDHeader); want != have {
+		t.Errorf("response %s: want %q, have %q", httpcontext.RequestIDHeader, want, have)
+	}
+	traceID := strconv.Itoa(caller.Context().(mocktracer.MockSpanContext).TraceID)
+	for _, want := range []string{"method=uppercase", "trace_id=" + traceID, "request_id=abc123"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("want %q in log, have %q", want, buf.String())
+		}
+	}
+}
@@ -1,11 +1,17 @@
 package main
 
 import (
+	"errors"
 	"flag"
+	"fmt"
+	"io"
 	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
-	"github.com/go-kit/kit/endpoint"
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/metrics"
 	kitprometheus "github.com/go-kit/kit/metrics/prometheus"
@@ -14,14 +20,31 @@ import (
 	stdopentracing "github.com/opentracing/opentracing-go"
 	stdprometheus "github.com/prometheus/client_golang/prometheus"
 	"golang.org/x/net/context"
+
+	"github.com/peterbourgon/go-microservices/pkg/health"
+	"github.com/peterbourgon/go-microservices/pkg/httpcontext"
+	"github.com/peterbourgon/go-microservices/pkg/shutdown"
+	"github.com/peterbourgon/go-microservices/pkg/tracing"
 )
 
 func main() {
 	// Configuration from the environment.
 	var (
-		httpAddr = flag.String("http.addr", ":8081", "HTTP listen address")
-		//tracerAddr = flag.String("tracer.addr", "", "Enable Tracer tracing via a Tracer server host:port")
+		httpAddr        = flag.String("http.addr", ":8081", "HTTP listen address")
+		shutdownDelay   = flag.Duration("shutdown.delay", 0, "time to keep serving with readiness failing before draining")
+		shutdownTimeout = flag.Duration("shutdown.timeout", 10*time.Second, "deadline for draining in-flight requests")
+		countMax        = flag.Int("count.max", defaultMaxCountSize, "maximum size of the input to count, in bytes (0 for no limit)")
+		normalizeMax    = flag.Int("normalize.max", defaultMaxNormalizeSize, "maximum size of the input to normalize, in bytes (0 for no limit)")
+		config          = DefaultConfig()
+		tracer          = tracing.DefaultConfig("stringsvc")
 	)
+	config.Uppercase.Flags(flag.CommandLine, "uppercase")
+	config.Lowercase.Flags(flag.CommandLine, "lowercase")
+	config.Titlecase.Flags(flag.CommandLine, "titlecase")
+	config.Count.Flags(flag.CommandLine, "count")
+	config.Normalize.Flags(flag.CommandLine, "normalize")
+	flag.IntVar(&config.MaxCallers, "callers.max", config.MaxCallers, "maximum number of callers tracked by per-caller rate limiters; API keys are unauthenticated, so new ones evict the least recently seen callers")
+	tracer.Flags(flag.CommandLine)
 	flag.Parse()
 
 	// Logging domain.
@@ -34,9 +57,22 @@ func main() {
 	logger.Log("msg", "hello")
 	defer logger.Log("msg", "goodbye")
 
+	if err := config.Validate(); err != nil {
+		logger.Log("err", err)
+		os.Exit(1)
+	}
+	if *countMax < 0 {
+		logger.Log("err", fmt.Sprintf("count max must not be negative, have %d", *countMax))
+		os.Exit(1)
+	}
+	if *normalizeMax < 0 {
+		logger.Log("err", fmt.Sprintf("normalize max must not be negative, have %d", *normalizeMax))
+		os.Exit(1)
+	}
+
 	// Metrics domain.
 	var requestCount metrics.Counter
-	var requestLatency, countResult metrics.Histogram
+	var requestLatency, countResult, duration metrics.Histogram
 	{
 		requestCount = kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
 			Namespace: "peterbourgon",
@@ -56,82 +92,138 @@ func main() {
 			Name:      "count_result",
 			Help:      "The result of each count method.",
 		}, []string{}) // no fields here
+		duration = kitprometheus.NewSummaryFrom(stdprometheus.SummaryOpts{
+			Namespace: "peterbourgon",
+			Subsystem: "stringsvc",
+			Name:      "request_duration_seconds",
+			Help:      "Endpoint duration in seconds, including rate limiting and circuit breaking.",
+		}, []string{"method", "success"})
 	}
 
 	// Tracing domain.
 	var trace stdopentracing.Tracer
+	var traceCloser io.Closer
 	{
-		//if *tracerAddr != "" {
-		//	logger.Log("tracer", *tracerAddr)
-		//	storer, err := tracer.NewGRPC(*tracerAddr, &tracer.GRPCOptions{
-		//		QueueSize:     1024,
-		//		FlushInterval: time.Second,
-		//	}, grpc.WithInsecure())
-		//	if err != nil {
-		//		logger.Log("err", err)
-		//		os.Exit(1)
-		//	}
-		//	trace = tracer.NewTracer("stringsvc", storer, tracer.RandomID{})
-		//} else {
-		logger.Log("tracer", "none")
-		trace = stdopentracing.GlobalTracer() // no-op
-		//}
+		var err error
+		trace, traceCloser, err = tracing.New(tracer, logger)
+		if err != nil {
+			logger.Log("err", err)
+			os.Exit(1)
+		}
+		logger.Log("tracer", tracer.Backend, "addr", tracer.Addr, "sample", tracer.SampleRate)
 	}
 
-	// Construct the service.
-	mux := makeServeMux(logger, requestCount, requestLatency, countResult, trace)
-	mux.Handle("/metrics", stdprometheus.Handler())
-
-	// Go!
-	logger.Log("transport", "HTTP", "addr", *httpAddr)
-	logger.Log("exit", http.ListenAndServe(*httpAddr, mux))
-}
-
-func makeServeMux(
-	logger log.Logger,
-	requestCount metrics.Counter,
-	requestLatency, countResult metrics.Histogram,
-	trace stdopentracing.Tracer,
-) *http.ServeMux {
 	// Business domain.
 	var svc StringService
 	{
-		svc = stringService{}
+		svc = stringService{maxCountSize: *countMax, maxNormalizeSize: *normalizeMax}
 		svc = loggingMiddleware{logger, svc}
 		svc = instrumentingMiddleware{requestCount, requestLatency, countResult, svc}
 	}
 
-	// Endpoint domain.
-	var uppercaseEndpoint endpoint.Endpoint
-	{
-		uppercaseEndpoint = makeUppercaseEndpoint(svc)
-		uppercaseEndpoint = opentracing.TraceServer(trace, "Uppercase")(uppercaseEndpoint)
+	// Endpoint and transport domains.
+	eps := New(svc, config, logger, duration, trace)
+	ready := health.NewFlag(errors.New("shutting down"))
+	mux := makeServeMux(eps, inputLimits{Count: *countMax, Normalize: *normalizeMax}, ready, logger, trace)
+	mux.Handle("/metrics", stdprometheus.Handler())
+
+	// Go!
+	server := &http.Server{Addr: *httpAddr, Handler: mux}
+	errc := make(chan error, 2)
+	go func() {
+		logger.Log("transport", "HTTP", "addr", *httpAddr)
+		if err := server.ListenAndServe(); err != http.ErrServerClosed {
+			errc <- err
+		}
+	}()
+	go func() {
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
+		errc <- fmt.Errorf("%s", <-c)
+	}()
+	logger.Log("exit", <-errc)
+
+	shutdown.Sequence{
+		Readiness:  ready,
+		Delay:      *shutdownDelay,
+		Timeout:    *shutdownTimeout,
+		Transports: map[string]shutdown.DrainFunc{"HTTP": server.Shutdown},
+		Tracer:     traceCloser,
+	}.Run(logger)
+}
+
+// makeServeMux mounts the routes, the OpenAPI document, and the liveness
+// and readiness checks. Readiness fails while any circuit breaker is open, or
+// once shutdown has failed; shutdown may be nil.
+func makeServeMux(endpoints Endpoints, limits inputLimits, shutdown *health.Flag, logger log.Logger, trace stdopentracing.Tracer) *http.ServeMux {
+	mux := http.NewServeMux()
+	for path, h := range makeRoutes(endpoints, limits, logger, trace) {
+		mux.Handle(path, h)
 	}
-	var countEndpoint endpoint.Endpoint
-	{
-		countEndpoint = makeCountEndpoint(svc)
-		countEndpoint = opentracing.TraceServer(trace, "Count")(countEndpoint)
+	mux.Handle("/openapi.json", openAPI())
+
+	// Liveness has no checks: if we can serve it, we're alive.
+	liveness, readiness := health.NewRegistry(), health.NewRegistry()
+	for name, cb := range endpoints.Breakers {
+		readiness.Register(strings.ToLower(name)+"_breaker", health.BreakerCheck(cb))
 	}
+	if shutdown != nil {
+		readiness.Register("shutdown", shutdown.Check)
+	}
+	mux.Handle("/healthz", liveness)
+	mux.Handle("/readyz", readiness)
 
-	// Transport domain.
-	mux := http.NewServeMux()
-	{
-		ctx := context.Background()
-		uppercaseHandler := httptransport.NewServer(
+	return mux
+}
+
+// makeRoutes returns the handlers of the API routes, by path. Each of them
+// should be described by the OpenAPI document. Request bodies are limited to
+// fit the limits of the inputs, which should match the service's.
+func makeRoutes(endpoints Endpoints, limits inputLimits, logger log.Logger, trace stdopentracing.Tracer) map[string]http.Handler {
+	ctx := context.Background()
+	options := func(method string) []httptransport.ServerOption {
+		return []httptransport.ServerOption{
+			httptransport.ServerErrorEncoder(errorEncoder),
+			httptransport.ServerErrorLogger(logger),
+			httptransport.ServerBefore(opentracing.FromHTTPRequest(trace, method, logger), httpcontext.PopulateRequestID, httpcontext.PopulateCaller),
+			httptransport.ServerAfter(httpcontext.SetRequestIDHeader),
+		}
+	}
+	return map[string]http.Handler{
+		"/uppercase": httptransport.NewServer(
+			ctx,
+			endpoints.UppercaseEndpoint,
+			decodeCaseRequest,
+			encodeResponse,
+			options("Uppercase")...,
+		),
+		"/lowercase": httptransport.NewServer(
+			ctx,
+			endpoints.LowercaseEndpoint,
+			decodeCaseRequest,
+			encodeResponse,
+			options("Lowercase")...,
+		),
+		"/titlecase": httptransport.NewServer(
 			ctx,
-			uppercaseEndpoint,
-			decodeUppercaseRequest,
+			endpoints.TitlecaseEndpoint,
+			decodeCaseRequest,
 			encodeResponse,
-		)
-		countHandler := httptransport.NewServer(
+			options("Titlecase")...,
+		),
+		"/count": httptransport.NewServer(
 			ctx,
-			countEndpoint,
-			decodeCountRequest,
+			endpoints.CountEndpoint,
+			decodeCountRequest(maxBodySize(limits.Count)),
 			encodeResponse,
-		)
-		mux.Handle("/uppercase", uppercaseHandler)
-		mux.Handle("/count", countHandler)
+			options("Count")...,
+		),
+		"/normalize": httptransport.NewServer(
+			ctx,
+			endpoints.NormalizeEndpoint,
+			decodeNormalizeRequest(maxBodySize(limits.Normalize)),
+			encodeResponse,
+			options("Normalize")...,
+		),
 	}
-
-	return mux
 }
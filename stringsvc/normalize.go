@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// NormalForm is a Unicode normalization form that Normalize converts a string
+// to.
+type NormalForm string
+
+const (
+	// NFC is canonical composition, e.g. e followed by a combining acute
+	// accent becomes é. It's the default, and the form that user input
+	// should usually be stored in.
+	NFC NormalForm = "NFC"
+
+	// NFD is canonical decomposition, e.g. é becomes e followed by a
+	// combining acute accent.
+	NFD NormalForm = "NFD"
+
+	// NFKC is compatibility composition, which additionally folds
+	// compatibility variants into their ordinary forms, e.g. the ﬁ ligature
+	// becomes fi, and full-width Ａ becomes A.
+	NFKC NormalForm = "NFKC"
+
+	// NFKD is compatibility decomposition.
+	NFKD NormalForm = "NFKD"
+)
+
+// UnknownFormError is returned by Normalize for a form other than those above.
+type UnknownFormError struct {
+	Form NormalForm
+}
+
+func (e UnknownFormError) Error() string {
+	return fmt.Sprintf("%v %q: want NFC, NFD, NFKC, or NFKD", ErrUnknownForm, string(e.Form))
+}
+
+// NormalizeOptions are the steps that Normalize applies to a string, in the
+// order they're declared: control characters are stripped, then the string is
+// normalized, then trimmed. Normalizing before trimming means that e.g. an
+// ideographic space, which NFKC maps to an ordinary space, is trimmed too.
+type NormalizeOptions struct {
+	// StripControl removes control characters, other than tab, line feed,
+	// and carriage return. Format characters, e.g. the zero width joiners
+	// in emoji sequences, are kept.
+	StripControl bool
+
+	// Form is the normal form to convert to. The empty form is NFC.
+	Form NormalForm
+
+	// Trim removes leading and trailing white space.
+	Trim bool
+}
+
+// normalize applies the options to s, which must be valid UTF-8.
+func normalize(s string, opts NormalizeOptions) (string, error) {
+	form, err := opts.Form.form()
+	if err != nil {
+		return "", err
+	}
+	if opts.StripControl {
+		s = strings.Map(dropControl, s)
+	}
+	s = form.String(s)
+	if opts.Trim {
+		s = strings.TrimSpace(s)
+	}
+	return s, nil
+}
+
+func (f NormalForm) form() (norm.Form, error) {
+	switch f {
+	case NFC, "":
+		return norm.NFC, nil
+	case NFD:
+		return norm.NFD, nil
+	case NFKC:
+		return norm.NFKC, nil
+	case NFKD:
+		return norm.NFKD, nil
+	}
+	return 0, UnknownFormError{Form: f}
+}
+
+// dropControl is a strings.Map mapping that drops control characters, other
+// than those that lay out text.
+func dropControl(r rune) rune {
+	switch r {
+	case '\t', '\n', '\r':
+		return r
+	}
+	if unicode.IsControl(r) {
+		return -1
+	}
+	return r
+}
@@ -0,0 +1,64 @@
+package main
+
+import "github.com/peterbourgon/go-microservices/pkg/openapi"
+
+// openAPI returns the OpenAPI document describing the routes served by
+// makeServeMux, with schemas generated from the request and response types.
+func openAPI() *openapi.Document {
+	d := openapi.New("stringsvc", "1.0.0")
+	rejected := openapi.JSONResponse("The request was rejected, e.g. by a rate limiter or circuit breaker.", errorResponse{})
+	badRequest := openapi.JSONResponse("The language tag isn't a well-formed BCP 47 tag.", errorResponse{})
+	d.Add("POST", "/uppercase", &openapi.Operation{
+		Summary:     "Uppercases a string, by the casing rules of the language, if any.",
+		OperationID: "uppercase",
+		RequestBody: openapi.JSONBody(caseRequest{S: "istanbul", Lang: "tr"}),
+		Responses: map[string]openapi.Response{
+			"200":     openapi.JSONResponse("The uppercased string, or an error.", caseResponse{}),
+			"400":     badRequest,
+			"default": rejected,
+		},
+	})
+	d.Add("POST", "/lowercase", &openapi.Operation{
+		Summary:     "Lowercases a string, by the casing rules of the language, if any.",
+		OperationID: "lowercase",
+		RequestBody: openapi.JSONBody(caseRequest{S: "ISTANBUL", Lang: "tr"}),
+		Responses: map[string]openapi.Response{
+			"200":     openapi.JSONResponse("The lowercased string, or an error.", caseResponse{}),
+			"400":     badRequest,
+			"default": rejected,
+		},
+	})
+	d.Add("POST", "/titlecase", &openapi.Operation{
+		Summary:     "Titlecases each word of a string, by the casing rules of the language, if any.",
+		OperationID: "titlecase",
+		RequestBody: openapi.JSONBody(caseRequest{S: "ijsland", Lang: "nl"}),
+		Responses: map[string]openapi.Response{
+			"200":     openapi.JSONResponse("The titlecased string, or an error.", caseResponse{}),
+			"400":     badRequest,
+			"default": rejected,
+		},
+	})
+	d.Add("POST", "/count", &openapi.Operation{
+		Summary:     "Counts the bytes, runes, graphemes, words, or lines in a string.",
+		OperationID: "count",
+		RequestBody: openapi.JSONBody(countRequest{S: "foo", Mode: Graphemes, Breakdown: true}),
+		Responses: map[string]openapi.Response{
+			"200":     openapi.JSONResponse("The count in the requested mode, bytes by default, with a breakdown in every mode if requested; or an error if the mode is unknown, or the string is too large or isn't valid UTF-8.", countResponse{}),
+			"400":     openapi.JSONResponse("The request body isn't valid UTF-8.", errorResponse{}),
+			"413":     openapi.JSONResponse("The request body is too large to carry a string within the maximum size, however it's escaped.", errorResponse{}),
+			"default": rejected,
+		},
+	})
+	d.Add("POST", "/normalize", &openapi.Operation{
+		Summary:     "Strips control characters from a string, converts it to a Unicode normal form, and trims white space, as requested.",
+		OperationID: "normalize",
+		RequestBody: openapi.JSONBody(normalizeRequest{S: " Cafe\u0301\x00 ", Form: NFC, StripControl: true, Trim: true}),
+		Responses: map[string]openapi.Response{
+			"200":     openapi.JSONResponse("The normalized string, or an error if the form is unknown or the string is too large.", normalizeResponse{}),
+			"400":     openapi.JSONResponse("The request body isn't valid UTF-8.", errorResponse{}),
+			"413":     openapi.JSONResponse("The request body is too large to carry a string within the maximum size, however it's escaped.", errorResponse{}),
+			"default": rejected,
+		},
+	})
+	return d
+}
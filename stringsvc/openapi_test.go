@@ -0,0 +1,36 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics/discard"
+	opentracing "github.com/opentracing/opentracing-go"
+
+	"github.com/peterbourgon/go-microservices/pkg/openapi"
+)
+
+func TestOpenAPI(t *testing.T) {
+	eps := New(stringService{}, DefaultConfig(), log.NewNopLogger(), discard.NewHistogram(), opentracing.GlobalTracer())
+	mux := makeServeMux(eps, inputLimits{}, nil, log.NewNopLogger(), opentracing.GlobalTracer())
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/openapi.json", nil))
+	if want, have := http.StatusOK, rec.Code; want != have {
+		t.Fatalf("want %d, have %d", want, have)
+	}
+	var served openapi.Document
+	if err := json.NewDecoder(rec.Body).Decode(&served); err != nil {
+		t.Fatal(err)
+	}
+	var routes []string
+	for path := range makeRoutes(eps, inputLimits{}, log.NewNopLogger(), opentracing.GlobalTracer()) {
+		routes = append(routes, path)
+	}
+	if err := openapi.Verify(mux, &served, routes...); err != nil {
+		t.Error(err)
+	}
+}
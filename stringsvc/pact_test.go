@@ -24,13 +24,8 @@ func TestConsumers(t *testing.T) {
 		t.Skip("no Pact files found")
 	}
 
-	mux := makeServeMux(
-		log.NewNopLogger(),
-		discard.NewCounter(),
-		discard.NewHistogram(),
-		discard.NewHistogram(),
-		opentracing.GlobalTracer(),
-	)
+	eps := New(stringService{}, DefaultConfig(), log.NewNopLogger(), discard.NewHistogram(), opentracing.GlobalTracer())
+	mux := makeServeMux(eps, inputLimits{}, nil, log.NewNopLogger(), opentracing.GlobalTracer())
 	mux.HandleFunc("/setup", func(w http.ResponseWriter, _ *http.Request) {
 		w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	})
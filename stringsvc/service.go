@@ -2,27 +2,138 @@ package main
 
 import (
 	"errors"
-	"strings"
+	"fmt"
+	"unicode/utf8"
+
+	"golang.org/x/net/context"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
 )
 
 // StringService provides operations on strings.
 type StringService interface {
-	Uppercase(string) (string, error)
-	Count(string) int
+	Uppercase(ctx context.Context, s string, lang language.Tag) (string, error)
+	Lowercase(ctx context.Context, s string, lang language.Tag) (string, error)
+	Titlecase(ctx context.Context, s string, lang language.Tag) (string, error)
+	Count(ctx context.Context, s string, mode CountMode, breakdown bool) (int, *Counts, error)
+	Normalize(ctx context.Context, s string, opts NormalizeOptions) (string, error)
+}
+
+// stringService is the basic implementation of StringService. The zero value
+// counts and normalizes inputs of any size.
+type stringService struct {
+	// maxCountSize is the maximum size of the input to Count, in bytes. Zero
+	// means no limit.
+	maxCountSize int
+
+	// maxNormalizeSize is the maximum size of the input to Normalize, in
+	// bytes. Zero means no limit.
+	maxNormalizeSize int
+}
+
+// defaultMaxCountSize is the maximum size of the input to Count, in bytes,
+// unless set with the -count.max flag.
+const defaultMaxCountSize = 1 << 20
+
+// defaultMaxNormalizeSize is the maximum size of the input to Normalize, in
+// bytes, unless set with the -normalize.max flag.
+const defaultMaxNormalizeSize = 1 << 20
+
+// Uppercase returns s in upper case, by the casing rules of the given
+// language, e.g. Turkish maps i to İ. The undetermined language, i.e. the
+// zero language.Tag, has no language-specific rules. In any language, ß maps
+// to SS.
+func (stringService) Uppercase(_ context.Context, s string, lang language.Tag) (string, error) {
+	return changeCase(cases.Upper(lang), s)
+}
+
+// Lowercase returns s in lower case, by the casing rules of the given language,
+// e.g. Turkish maps I to ı.
+func (stringService) Lowercase(_ context.Context, s string, lang language.Tag) (string, error) {
+	return changeCase(cases.Lower(lang), s)
 }
 
-type stringService struct{}
+// Titlecase returns s with the first letter of each word in title case, and
+// the rest in lower case, by the casing rules of the given language, e.g.
+// Dutch maps ijsland to IJsland.
+func (stringService) Titlecase(_ context.Context, s string, lang language.Tag) (string, error) {
+	return changeCase(cases.Title(lang), s)
+}
 
-func (stringService) Uppercase(s string) (string, error) {
+// changeCase applies the caser, which is stateful, so mustn't be shared
+// between calls.
+func changeCase(c cases.Caser, s string) (string, error) {
 	if s == "" {
 		return "", ErrEmpty
 	}
-	return strings.ToUpper(s), nil
+	return c.String(s), nil
+}
+
+// Count returns the size of s in the given mode, and, if breakdown is set, its
+// breakdown in every mode. Otherwise, only the given mode is measured, and the
+// breakdown is nil. The empty mode is Bytes. It returns an UnknownModeError for
+// any other mode, an InputSizeError if s is larger than the maximum size, and
+// ErrInvalidUTF8 if s isn't valid UTF-8.
+func (svc stringService) Count(_ context.Context, s string, mode CountMode, breakdown bool) (int, *Counts, error) {
+	if _, err := (Counts{}).In(mode); err != nil {
+		return 0, nil, err
+	}
+	if svc.maxCountSize > 0 && len(s) > svc.maxCountSize {
+		return 0, nil, InputSizeError{Limit: svc.maxCountSize, Size: len(s)}
+	}
+	if !utf8.ValidString(s) {
+		return 0, nil, ErrInvalidUTF8
+	}
+	if !breakdown {
+		return count(s, mode), nil, nil
+	}
+	counts := countAll(s)
+	n, _ := counts.In(mode)
+	return n, &counts, nil
+}
+
+// Normalize returns s with the cleanup steps and normalization of the options
+// applied. Unlike the case operations, it accepts the empty string, as
+// cleaning up other input may produce it anyway. It returns an
+// UnknownFormError for an unknown normal form, an InputSizeError if s is
+// larger than the maximum size, and ErrInvalidUTF8 if s isn't valid UTF-8.
+func (svc stringService) Normalize(_ context.Context, s string, opts NormalizeOptions) (string, error) {
+	if svc.maxNormalizeSize > 0 && len(s) > svc.maxNormalizeSize {
+		return "", InputSizeError{Limit: svc.maxNormalizeSize, Size: len(s)}
+	}
+	if !utf8.ValidString(s) {
+		return "", ErrInvalidUTF8
+	}
+	return normalize(s, opts)
 }
 
-func (stringService) Count(s string) int {
-	return len(s)
+var (
+	// ErrEmpty is returned when an input string is empty.
+	ErrEmpty = errors.New("empty string")
+
+	// ErrInputTooLarge is returned, as an InputSizeError, when an input
+	// string is larger than the maximum size.
+	ErrInputTooLarge = errors.New("input exceeds maximum size")
+
+	// ErrInvalidUTF8 is returned when an input string isn't valid UTF-8.
+	ErrInvalidUTF8 = errors.New("invalid UTF-8")
+
+	// ErrUnknownMode is returned, as an UnknownModeError, when Count is asked
+	// for a mode it doesn't know.
+	ErrUnknownMode = errors.New("unknown count mode")
+
+	// ErrUnknownForm is returned, as an UnknownFormError, when Normalize is
+	// asked for a normal form it doesn't know.
+	ErrUnknownForm = errors.New("unknown normal form")
+)
+
+// InputSizeError is returned when an input string is larger than the maximum
+// size, in bytes.
+type InputSizeError struct {
+	Limit int
+	Size  int
 }
 
-// ErrEmpty is returned when an input string is empty.
-var ErrEmpty = errors.New("empty string")
+func (e InputSizeError) Error() string {
+	return fmt.Sprintf("%v: %d bytes, limit %d", ErrInputTooLarge, e.Size, e.Limit)
+}
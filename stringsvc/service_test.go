@@ -0,0 +1,167 @@
+package main
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+	"golang.org/x/text/language"
+)
+
+func TestCount(t *testing.T) {
+	svc := stringService{maxCountSize: 8}
+	for _, testcase := range []struct {
+		s    string
+		want int
+		err  error
+	}{
+		{"", 0, nil},
+		{"foo", 3, nil},
+		{"héllo", 6, nil},
+		{"12345678", 8, nil},
+		{"123456789", 0, InputSizeError{Limit: 8, Size: 9}},
+		{"\xff", 0, ErrInvalidUTF8},
+		{"ab\xc3", 0, ErrInvalidUTF8}, // truncated
+	} {
+		n, _, err := svc.Count(context.Background(), testcase.s, Bytes, false)
+		if want, have := testcase.err, err; want != have {
+			t.Errorf("Count(%q): want error %v, have %v", testcase.s, want, have)
+		}
+		if want, have := testcase.want, n; want != have {
+			t.Errorf("Count(%q): want %d, have %d", testcase.s, want, have)
+		}
+	}
+}
+
+func TestCountModes(t *testing.T) {
+	svc := stringService{}
+	for _, testcase := range []struct {
+		name string
+		s    string
+		want Counts
+	}{
+		{"empty", "", Counts{}},
+		{"ASCII", "hello, world", Counts{Bytes: 12, Runes: 12, Graphemes: 12, Words: 2, Lines: 1}},
+		{"combining marks", "e\u0301te\u0301", Counts{Bytes: 7, Runes: 5, Graphemes: 3, Words: 1, Lines: 1}},
+		{"stacked combining marks", "Z\u0351\u036b\u0343", Counts{Bytes: 7, Runes: 4, Graphemes: 1, Words: 1, Lines: 1}},
+		{"Devanagari", "नमस्ते", Counts{Bytes: 18, Runes: 6, Graphemes: 4, Words: 1, Lines: 1}},
+		{"emoji ZWJ sequence", "\U0001f468\u200d\U0001f469\u200d\U0001f467\u200d\U0001f466", Counts{Bytes: 25, Runes: 7, Graphemes: 1, Words: 0, Lines: 1}},
+		{"emoji with skin tone", "hi \U0001f44b\U0001f3fd", Counts{Bytes: 11, Runes: 5, Graphemes: 4, Words: 1, Lines: 1}},
+		{"flag", "\U0001f1ef\U0001f1f5 flag", Counts{Bytes: 13, Runes: 7, Graphemes: 6, Words: 1, Lines: 1}},
+		{"Japanese", "日本語のテキスト", Counts{Bytes: 24, Runes: 8, Graphemes: 8, Words: 5, Lines: 1}},
+		{"Chinese", "你好，世界", Counts{Bytes: 15, Runes: 5, Graphemes: 5, Words: 4, Lines: 1}},
+		{"Korean", "한국어 텍스트", Counts{Bytes: 19, Runes: 7, Graphemes: 7, Words: 2, Lines: 1}},
+		{"lines", "a\nb\r\nc", Counts{Bytes: 6, Runes: 6, Graphemes: 5, Words: 3, Lines: 3}},
+		{"trailing newline", "a\n", Counts{Bytes: 2, Runes: 2, Graphemes: 2, Words: 1, Lines: 1}},
+		{"blank lines", "\n\n", Counts{Bytes: 2, Runes: 2, Graphemes: 2, Words: 0, Lines: 2}},
+		{"line separator", "a\u2028b", Counts{Bytes: 5, Runes: 3, Graphemes: 3, Words: 2, Lines: 2}},
+	} {
+		for _, mode := range []CountMode{"", Bytes, Runes, Graphemes, Words, Lines} {
+			for _, breakdown := range []bool{false, true} {
+				n, counts, err := svc.Count(context.Background(), testcase.s, mode, breakdown)
+				if err != nil {
+					t.Errorf("%s, %q: %v", testcase.name, mode, err)
+					continue
+				}
+				switch {
+				case !breakdown && counts != nil:
+					t.Errorf("%s, %q: want no breakdown, have %+v", testcase.name, mode, *counts)
+				case breakdown && counts == nil:
+					t.Errorf("%s, %q: want %+v, have no breakdown", testcase.name, mode, testcase.want)
+				case breakdown && *counts != testcase.want:
+					t.Errorf("%s, %q: want %+v, have %+v", testcase.name, mode, testcase.want, *counts)
+				}
+				if want, _ := testcase.want.In(mode); want != n {
+					t.Errorf("%s, %q, breakdown %v: want %d, have %d", testcase.name, mode, breakdown, want, n)
+				}
+			}
+		}
+	}
+}
+
+func TestCountUnknownMode(t *testing.T) {
+	_, _, err := stringService{}.Count(context.Background(), "foo", "chars", false)
+	if want, have := (UnknownModeError{Mode: "chars"}), err; want != have {
+		t.Errorf("want %v, have %v", want, have)
+	}
+}
+
+func TestCase(t *testing.T) {
+	svc := stringService{}
+	for _, testcase := range []struct {
+		op   string
+		s    string
+		lang language.Tag
+		want string
+	}{
+		{"upper", "istanbul", language.Und, "ISTANBUL"},
+		{"upper", "istanbul", language.Turkish, "İSTANBUL"},
+		{"upper", "istanbul", language.Azerbaijani, "İSTANBUL"},
+		{"upper", "straße", language.Und, "STRASSE"},
+		{"upper", "straße", language.German, "STRASSE"},
+		{"upper", "άδεια", language.Greek, "ΑΔΕΙΑ"}, // accents dropped
+		{"lower", "ISPARTA", language.Und, "isparta"},
+		{"lower", "ISPARTA", language.Turkish, "ısparta"},
+		{"lower", "İZMİR", language.Turkish, "izmir"},
+		{"lower", "STRASSE", language.German, "strasse"},
+		{"title", "ijsland", language.Und, "Ijsland"},
+		{"title", "ijsland", language.Dutch, "IJsland"},
+		{"title", "iyi günler", language.Turkish, "İyi Günler"},
+		{"title", "hELLO wORLD", language.English, "Hello World"},
+	} {
+		var (
+			have string
+			err  error
+		)
+		switch testcase.op {
+		case "upper":
+			have, err = svc.Uppercase(context.Background(), testcase.s, testcase.lang)
+		case "lower":
+			have, err = svc.Lowercase(context.Background(), testcase.s, testcase.lang)
+		case "title":
+			have, err = svc.Titlecase(context.Background(), testcase.s, testcase.lang)
+		}
+		if err != nil {
+			t.Errorf("%s(%q, %v): %v", testcase.op, testcase.s, testcase.lang, err)
+			continue
+		}
+		if want := testcase.want; want != have {
+			t.Errorf("%s(%q, %v): want %q, have %q", testcase.op, testcase.s, testcase.lang, want, have)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	svc := stringService{maxNormalizeSize: 16}
+	for _, testcase := range []struct {
+		s    string
+		opts NormalizeOptions
+		want string
+		err  error
+	}{
+		{"", NormalizeOptions{}, "", nil},
+		{"Cafe\u0301", NormalizeOptions{}, "Caf\u00e9", nil},
+		{"Cafe\u0301", NormalizeOptions{Form: NFC}, "Caf\u00e9", nil},
+		{"Caf\u00e9", NormalizeOptions{Form: NFD}, "Cafe\u0301", nil},
+		{"\ufb01\uff21\u00b2", NormalizeOptions{Form: NFC}, "\ufb01\uff21\u00b2", nil},
+		{"\ufb01\uff21\u00b2", NormalizeOptions{Form: NFKC}, "fiA2", nil},
+		{"\u1e9b\u0323", NormalizeOptions{Form: NFKD}, "s\u0323\u0307", nil},
+		{"  foo\t\n", NormalizeOptions{Trim: true}, "foo", nil},
+		{"\u3000foo\u3000", NormalizeOptions{Trim: true}, "foo", nil}, // ideographic spaces
+		{"a\x00b\x1bc\u0085d\x7f", NormalizeOptions{StripControl: true}, "abcd", nil},
+		{"a\tb\r\nc", NormalizeOptions{StripControl: true}, "a\tb\r\nc", nil},
+		{"\U0001f468\u200d\U0001f469", NormalizeOptions{StripControl: true}, "\U0001f468\u200d\U0001f469", nil}, // ZWJ kept
+		{"e\x00\u0301", NormalizeOptions{StripControl: true}, "\u00e9", nil},                                    // composed once the control character is gone
+		{" \x00foo\x00 ", NormalizeOptions{StripControl: true, Trim: true}, "foo", nil},
+		{"foo", NormalizeOptions{Form: "NFX"}, "", UnknownFormError{Form: "NFX"}},
+		{"\xff", NormalizeOptions{}, "", ErrInvalidUTF8},
+		{"  0123456789abcdef  ", NormalizeOptions{Trim: true}, "", InputSizeError{Limit: 16, Size: 20}}, // before trimming
+	} {
+		have, err := svc.Normalize(context.Background(), testcase.s, testcase.opts)
+		if want, have := testcase.err, err; want != have {
+			t.Errorf("Normalize(%q, %+v): want error %v, have %v", testcase.s, testcase.opts, want, have)
+		}
+		if want := testcase.want; want != have {
+			t.Errorf("Normalize(%q, %+v): want %q, have %q", testcase.s, testcase.opts, want, have)
+		}
+	}
+}
@@ -2,45 +2,132 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
+	"unicode/utf8"
 
-	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/ratelimit"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/sony/gobreaker"
 	"golang.org/x/net/context"
+	"golang.org/x/text/language"
+
+	"github.com/peterbourgon/go-microservices/pkg/httpcontext"
+	"github.com/peterbourgon/go-microservices/pkg/middleware"
 )
 
-func makeUppercaseEndpoint(svc StringService) endpoint.Endpoint {
-	return func(ctx context.Context, request interface{}) (interface{}, error) {
-		req := request.(uppercaseRequest)
-		v, err := svc.Uppercase(req.S)
-		if err != nil {
-			return uppercaseResponse{v, err.Error()}, nil
+// decodeCaseRequest decodes requests to Uppercase, Lowercase, and Titlecase.
+// It parses the language tag, if any, so that a malformed one is rejected as a
+// bad request, with a LanguageTagError. A well-formed tag with unknown subtags
+// is accepted without them, e.g. tr-Qzzz as tr, and zz as the undetermined
+// language, as the casing rules don't depend on them.
+func decodeCaseRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var request caseRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return nil, err
+	}
+	if request.Lang != "" {
+		tag, err := language.Parse(request.Lang)
+		if _, unknown := err.(language.ValueError); err != nil && !unknown {
+			return nil, LanguageTagError{Tag: request.Lang, Err: err}
 		}
-		return uppercaseResponse{v, ""}, nil
+		request.tag = tag
 	}
+	return request, nil
+}
+
+// ErrInvalidLanguageTag is returned, as a LanguageTagError, when a request's
+// language tag isn't a well-formed BCP 47 tag.
+var ErrInvalidLanguageTag = errors.New("invalid language tag")
+
+// LanguageTagError is returned when a request's language tag isn't a
+// well-formed BCP 47 tag, with the reason it was rejected.
+type LanguageTagError struct {
+	Tag string
+	Err error
+}
+
+func (e LanguageTagError) Error() string {
+	return fmt.Sprintf("%v %q: %v", ErrInvalidLanguageTag, e.Tag, e.Err)
+}
+
+// inputLimits are the maximum sizes of the inputs of the routes that limit
+// them, in bytes, from which the maximum sizes of their request bodies are
+// derived. Zero means no limit.
+type inputLimits struct {
+	Count     int
+	Normalize int
 }
 
-func makeCountEndpoint(svc StringService) endpoint.Endpoint {
-	return func(ctx context.Context, request interface{}) (interface{}, error) {
-		req := request.(countRequest)
-		v := svc.Count(req.S)
-		return countResponse{v}, nil
+// maxEscapedSize is the most bytes JSON may take to encode one byte of an
+// input string, as in \u00e9.
+const maxEscapedSize = len(`\u0000`)
+
+// maxBodyOverhead is the room allowed for the fields of a request body other
+// than the input string, and white space.
+const maxBodyOverhead = 1 << 10
+
+// maxBodySize returns the maximum size of a request body carrying an input of
+// at most n bytes, however it's escaped. Zero means no limit.
+func maxBodySize(n int) int64 {
+	if n <= 0 {
+		return 0
 	}
+	return int64(n)*int64(maxEscapedSize) + maxBodyOverhead
 }
 
-func decodeUppercaseRequest(_ context.Context, r *http.Request) (interface{}, error) {
-	var request uppercaseRequest
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		return nil, err
+// decodeCountRequest returns a decoder of requests to Count, whose bodies may
+// be at most max bytes. Zero means no limit.
+func decodeCountRequest(max int64) httptransport.DecodeRequestFunc {
+	return func(_ context.Context, r *http.Request) (interface{}, error) {
+		var request countRequest
+		if err := decodeUTF8JSON(r, max, &request); err != nil {
+			return nil, err
+		}
+		return request, nil
 	}
-	return request, nil
 }
 
-func decodeCountRequest(_ context.Context, r *http.Request) (interface{}, error) {
-	var request countRequest
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		return nil, err
+// decodeNormalizeRequest returns a decoder of requests to Normalize, whose
+// bodies may be at most max bytes. Zero means no limit.
+func decodeNormalizeRequest(max int64) httptransport.DecodeRequestFunc {
+	return func(_ context.Context, r *http.Request) (interface{}, error) {
+		var request normalizeRequest
+		if err := decodeUTF8JSON(r, max, &request); err != nil {
+			return nil, err
+		}
+		return request, nil
 	}
-	return request, nil
+}
+
+// ErrBodyTooLarge is returned when a request body is larger than the route
+// allows.
+var ErrBodyTooLarge = errors.New("request body too large")
+
+// decodeUTF8JSON reads a body of at most max bytes, or any size if max is
+// zero, failing with ErrBodyTooLarge beyond that. It rejects bodies that
+// aren't valid UTF-8 with ErrInvalidUTF8, as they aren't valid JSON, and
+// package json would otherwise silently replace the invalid bytes before the
+// service could reject them.
+func decodeUTF8JSON(r *http.Request, max int64, v interface{}) error {
+	body := io.Reader(r.Body)
+	if max > 0 {
+		body = io.LimitReader(body, max+1)
+	}
+	buf, err := ioutil.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	if max > 0 && int64(len(buf)) > max {
+		return ErrBodyTooLarge
+	}
+	if !utf8.Valid(buf) {
+		return ErrInvalidUTF8
+	}
+	return json.Unmarshal(buf, v)
 }
 
 func encodeResponse(_ context.Context, w http.ResponseWriter, response interface{}) error {
@@ -48,19 +135,77 @@ func encodeResponse(_ context.Context, w http.ResponseWriter, response interface
 	return json.NewEncoder(w).Encode(response)
 }
 
-type uppercaseRequest struct {
-	S string `json:"s"`
+// errorEncoder writes errors returned by the endpoints, i.e. those from the
+// endpoint middlewares, and request decoding errors, with the same status
+// codes as addsvc. Errors from the service itself are returned in the response
+// body.
+func errorEncoder(ctx context.Context, err error, w http.ResponseWriter) {
+	code := err2code(err)
+	httpcontext.SetRequestIDHeader(ctx, w)
+	if e, ok := err.(httptransport.Error); ok {
+		err = e.Err
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(errorResponse{Err: err.Error()})
+}
+
+func err2code(err error) int {
+	e, ok := err.(httptransport.Error)
+	if !ok {
+		return http.StatusInternalServerError
+	}
+	switch e.Err {
+	case middleware.ErrRateLimited, ratelimit.ErrLimited:
+		return http.StatusTooManyRequests
+	case gobreaker.ErrOpenState, gobreaker.ErrTooManyRequests:
+		return http.StatusServiceUnavailable
+	}
+	if e.Domain == httptransport.DomainDecode {
+		if e.Err == ErrBodyTooLarge {
+			return http.StatusRequestEntityTooLarge
+		}
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+type errorResponse struct {
+	Err string `json:"err"`
+}
+
+type caseRequest struct {
+	S    string `json:"s"`
+	Lang string `json:"lang,omitempty"` // BCP 47 language tag, e.g. tr or de-CH
+
+	tag language.Tag // parsed from Lang by decodeCaseRequest
 }
 
-type uppercaseResponse struct {
+type caseResponse struct {
 	V   string `json:"v"`
 	Err string `json:"err,omitempty"`
 }
 
 type countRequest struct {
-	S string `json:"s"`
+	S         string    `json:"s"`
+	Mode      CountMode `json:"mode,omitempty"`      // bytes, if empty
+	Breakdown bool      `json:"breakdown,omitempty"` // in every mode, too
 }
 
 type countResponse struct {
-	V int `json:"v"`
+	V         int     `json:"v"`
+	Breakdown *Counts `json:"breakdown,omitempty"` // if requested
+	Err       string  `json:"err,omitempty"`
+}
+
+type normalizeRequest struct {
+	S            string     `json:"s"`
+	Form         NormalForm `json:"form,omitempty"`          // NFC, if empty
+	StripControl bool       `json:"strip_control,omitempty"` // before normalizing
+	Trim         bool       `json:"trim,omitempty"`          // after normalizing
+}
+
+type normalizeResponse struct {
+	V   string `json:"v"`
+	Err string `json:"err,omitempty"`
 }